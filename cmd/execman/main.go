@@ -4,14 +4,28 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/sfkleach/execman/pkg/adopt"
+	"github.com/sfkleach/execman/pkg/bundle"
 	"github.com/sfkleach/execman/pkg/check"
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/export"
 	"github.com/sfkleach/execman/pkg/forget"
+	"github.com/sfkleach/execman/pkg/httpclient"
 	initpkg "github.com/sfkleach/execman/pkg/init"
 	"github.com/sfkleach/execman/pkg/install"
 	"github.com/sfkleach/execman/pkg/list"
+	"github.com/sfkleach/execman/pkg/lockfile"
+	"github.com/sfkleach/execman/pkg/mirror"
+	"github.com/sfkleach/execman/pkg/policy"
+	"github.com/sfkleach/execman/pkg/registry"
 	"github.com/sfkleach/execman/pkg/remove"
+	"github.com/sfkleach/execman/pkg/rollback"
+	"github.com/sfkleach/execman/pkg/scan"
+	syncpkg "github.com/sfkleach/execman/pkg/sync"
 	"github.com/sfkleach/execman/pkg/update"
+	"github.com/sfkleach/execman/pkg/use"
 	"github.com/sfkleach/execman/pkg/version"
+	"github.com/sfkleach/execman/pkg/versions"
 	"github.com/spf13/cobra"
 )
 
@@ -22,12 +36,36 @@ var (
 	installInto               string
 	installYes                bool
 	installIncludePrereleases bool
+	installLayout             string
+	installSource             string
+	installVersion            string
+	installChecksums          string
+	installName               string
+	installLatestURL          string
+	installLatestPath         string
+	installChecksumsURL       string
+	installBuildFromSource    bool
+	installAllowMoved         bool
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "execman",
 	Short: "Execman - Executable manager",
 	Long:  `Execman is a command-line tool for managing executables.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// A config that cannot be loaded is reported by the commands that
+		// need it, so that commands such as init still run.
+		cfg, err := config.Load()
+		if err != nil {
+			return nil
+		}
+		if err := httpclient.Configure(cfg); err != nil {
+			// A bad network setting is a config problem, not a usage one.
+			cmd.SilenceUsage = true
+			return fmt.Errorf("invalid network settings in config: %w", err)
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if versionFlag {
 			if err := version.ShowVersion(false, false); err != nil {
@@ -41,24 +79,67 @@ var rootCmd = &cobra.Command{
 }
 
 var installCmd = &cobra.Command{
-	Use:   "install <github.com/owner/repo>[@version]",
-	Short: "Install an executable from GitHub",
-	Long:  `Install an executable from a GitHub release`,
-	Args:  cobra.ExactArgs(1),
+	Use:   "install <github.com/owner/repo>[@version] | <url-template> | <oci://registry/repository> | <archive> --source <source>",
+	Short: "Install an executable from GitHub, a download server or an OCI registry",
+	Long: `Install an executable from a GitHub release.
+
+For tools published on their own download server, give a URL template instead,
+such as https://releases.example.com/tool/{version}/tool_{version}_{os}_{arch}.zip.
+Templates may use {version}, {bare_version} (without a leading v), {os} and {arch}.
+Use --latest-url to say where the latest version is published, as plain text or,
+with --latest-path, as a JSON document, so that check and update work too.
+
+For binaries published as OCI artifacts, give oci://registry/repository. The newest
+version tag is installed unless --version names one.
+
+Given a local release archive and --source, install it without any network access,
+recording it as that release of the source. --checksums names a local checksums file
+that must list the archive.
+
+With --build-from-source, a Go tool whose GitHub release has no asset for this
+platform is built with go install at the release tag instead, if a Go toolchain is
+on the PATH. Updates build it again for as long as its releases have no asset.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		opts := install.Options{
-			Source:             args[0],
-			Into:               installInto,
-			Yes:                installYes,
-			IncludePrereleases: installIncludePrereleases,
-		}
-		if err := install.Run(opts); err != nil {
+		if err := runInstall(args); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 	},
 }
 
+// runInstall runs the install command with the parsed flags.
+func runInstall(args []string) error {
+	opts := install.Options{
+		Source:             args[0],
+		Into:               installInto,
+		Yes:                installYes,
+		IncludePrereleases: installIncludePrereleases,
+		Layout:             installLayout,
+		Version:            installVersion,
+		Name:               installName,
+		BuildFromSource:    installBuildFromSource,
+		AllowMoved:         installAllowMoved,
+	}
+	if installLatestURL != "" || installLatestPath != "" || installChecksumsURL != "" {
+		opts.URLSource = &registry.URLSource{
+			ChecksumsURL: installChecksumsURL,
+			LatestURL:    installLatestURL,
+			LatestPath:   installLatestPath,
+		}
+	}
+	if installSource != "" {
+		opts.Source = installSource
+		opts.ArchivePath = args[0]
+		opts.ChecksumsPath = installChecksums
+	} else if info, err := os.Stat(args[0]); err == nil && !info.IsDir() {
+		return fmt.Errorf("%s is a local file; name the source it was released from with --source", args[0])
+	} else if installChecksums != "" {
+		return fmt.Errorf("--checksums is only used with --source to install a local archive")
+	}
+	return install.Run(opts)
+}
+
 func init() {
 	rootCmd.PersistentFlags().BoolVar(&versionFlag, "version", false, "Print version information")
 
@@ -66,15 +147,38 @@ func init() {
 	installCmd.Flags().StringVarP(&installInto, "into", "d", "", "Install to specified directory")
 	installCmd.Flags().BoolVarP(&installYes, "yes", "y", false, "Skip confirmation prompts")
 	installCmd.Flags().BoolVar(&installIncludePrereleases, "include-prereleases", false, "Allow installing prerelease versions")
+	installCmd.Flags().StringVar(&installLayout, "layout", "", "Install layout: copy or versioned (default from config)")
+	installCmd.Flags().StringVar(&installSource, "source", "", "Install the argument as a local archive of this GitHub repository")
+	installCmd.Flags().StringVar(&installVersion, "version", "", "Release version to install (default latest)")
+	installCmd.Flags().StringVar(&installChecksums, "checksums", "", "Verify a local archive against this checksums file")
+	installCmd.Flags().StringVar(&installName, "name", "", "Executable name (default the repository name, or a URL template's file name)")
+	installCmd.Flags().StringVar(&installLatestURL, "latest-url", "", "URL publishing the latest version of a URL template source")
+	installCmd.Flags().StringVar(&installLatestPath, "latest-path", "", "JSONPath to the version in --latest-url's JSON, e.g. $.current_version")
+	installCmd.Flags().StringVar(&installChecksumsURL, "checksums-url", "", "URL template of a checksums file listing a URL template's assets")
+	installCmd.Flags().BoolVar(&installBuildFromSource, "build-from-source", false, "Build a Go module with go install if its release has no asset for this platform")
+	installCmd.Flags().BoolVar(&installAllowMoved, "allow-moved", false, "Accept an upstream repository that was renamed or transferred")
 
 	rootCmd.AddCommand(version.NewVersionCommand())
 	rootCmd.AddCommand(initpkg.NewInitCommand())
 	rootCmd.AddCommand(installCmd)
+	rootCmd.AddCommand(adopt.NewAdoptCommand())
+	rootCmd.AddCommand(scan.NewScanCommand())
+	rootCmd.AddCommand(syncpkg.NewSyncCommand())
+	rootCmd.AddCommand(lockfile.NewLockCommand())
+	rootCmd.AddCommand(lockfile.NewRestoreCommand())
+	rootCmd.AddCommand(export.NewExportCommand())
+	rootCmd.AddCommand(export.NewImportCommand())
+	rootCmd.AddCommand(bundle.NewBundleCommand())
+	rootCmd.AddCommand(mirror.NewServeMirrorCommand())
 	rootCmd.AddCommand(list.NewListCommand())
 	rootCmd.AddCommand(check.NewCheckCommand())
 	rootCmd.AddCommand(update.NewUpdateCommand())
 	rootCmd.AddCommand(remove.NewRemoveCommand())
+	rootCmd.AddCommand(rollback.NewRollbackCommand())
+	rootCmd.AddCommand(use.NewUseCommand())
+	rootCmd.AddCommand(versions.NewVersionsCommand())
 	rootCmd.AddCommand(forget.NewForgetCommand())
+	rootCmd.AddCommand(policy.NewPolicyCommand())
 }
 
 func main() {
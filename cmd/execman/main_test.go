@@ -0,0 +1,124 @@
+package main
+
+import (
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+// writeProxyModule adds github.com/acme/hello v1.0.0, a main package, to a
+// file-based module proxy.
+func writeProxyModule(t *testing.T, proxy string) {
+	t.Helper()
+	const module, version = "github.com/acme/hello", "v1.0.0"
+	files := map[string]string{
+		"go.mod":  "module " + module + "\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() { println(\"hello\") }\n",
+	}
+	dir := filepath.Join(proxy, filepath.FromSlash(module), "@v")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create proxy directory: %v", err)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	write("list", version+"\n")
+	write(version+".info", `{"Version":"`+version+`","Time":"2026-01-01T00:00:00Z"}`)
+	write(version+".mod", files["go.mod"])
+
+	out, err := os.Create(filepath.Join(dir, version+".zip"))
+	if err != nil {
+		t.Fatalf("failed to create zip: %v", err)
+	}
+	zw := zip.NewWriter(out)
+	for name, content := range files {
+		w, err := zw.Create(module + "@" + version + "/" + name)
+		if err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+}
+
+func TestInstallBuildFromSourceFlag(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no Go toolchain")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	proxy := t.TempDir()
+	writeProxyModule(t, proxy)
+	t.Setenv("GOPROXY", "file://"+filepath.ToSlash(proxy))
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOTOOLCHAIN", "local")
+	t.Setenv("GOMODCACHE", t.TempDir())
+	t.Setenv("GOFLAGS", "-modcacherw")
+
+	// The release has no assets at all, so only building it can succeed.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/hello":
+			_, _ = w.Write([]byte(`{"id": 42, "full_name": "acme/hello"}`))
+		case "/repos/acme/hello/releases/tags/v1.0.0":
+			_, _ = w.Write([]byte(`{"tag_name": "v1.0.0", "assets": []}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	savedBaseURL := github.APIBaseURL
+	github.APIBaseURL = server.URL
+	defer func() { github.APIBaseURL = savedBaseURL }()
+
+	into := filepath.Join(home, "bin")
+	if err := os.MkdirAll(into, 0755); err != nil {
+		t.Fatalf("failed to create install directory: %v", err)
+	}
+	args := []string{"acme/hello", "--build-from-source", "--yes", "--version", "v1.0.0", "--into", into}
+	if err := installCmd.ParseFlags(args); err != nil {
+		t.Fatalf("ParseFlags returned error: %v", err)
+	}
+	defer func() {
+		installBuildFromSource, installYes, installVersion, installInto = false, false, "", ""
+	}()
+
+	if err := runInstall(installCmd.Flags().Args()); err != nil {
+		t.Fatalf("runInstall returned error: %v", err)
+	}
+
+	reg, err := registry.Load()
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+	entry, found := reg.Get("hello")
+	if !found {
+		t.Fatal("hello is not in the registry")
+	}
+	if entry.BuiltFrom != "github.com/acme/hello" {
+		t.Errorf("BuiltFrom = %q, want github.com/acme/hello", entry.BuiltFrom)
+	}
+	if _, err := os.Stat(entry.Path); err != nil {
+		t.Errorf("installed binary is missing: %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+// Package adopt brings an executable that was installed without execman
+// under management.
+package adopt
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/install"
+	"github.com/sfkleach/execman/pkg/policy"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+// DefaultSearchReleases is how many recent releases are checked when
+// inferring the version of an adopted executable.
+const DefaultSearchReleases = 5
+
+// Options for the adopt command.
+type Options struct {
+	Path           string
+	Source         string
+	Version        string // Empty means infer it from the release assets.
+	Yes            bool
+	AllowMoved     bool
+	SearchReleases int
+}
+
+// NewAdoptCommand creates the adopt command.
+func NewAdoptCommand() *cobra.Command {
+	var source string
+	var version string
+	var yes bool
+	var allowMoved bool
+	var searchReleases int
+
+	cmd := &cobra.Command{
+		Use:   "adopt <path> --source github.com/owner/repo",
+		Short: "Manage an executable that was installed without execman",
+		Long: `Register an existing executable with execman, asserting that it came from the given source.
+Unless --version is given, the version is inferred by comparing the file's checksum with
+the binaries in recent releases. If none matches, the version is recorded as "unknown" and
+the next update replaces the file with the latest release.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := Options{
+				Path:           args[0],
+				Source:         source,
+				Version:        version,
+				Yes:            yes,
+				AllowMoved:     allowMoved,
+				SearchReleases: searchReleases,
+			}
+			return Run(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&source, "source", "", "GitHub repository the executable came from (required)")
+	cmd.Flags().StringVar(&version, "version", "", "Version of the executable, if known")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+	cmd.Flags().BoolVar(&allowMoved, "allow-moved", false, "Accept an upstream repository that was renamed or transferred")
+	cmd.Flags().IntVar(&searchReleases, "search", DefaultSearchReleases, "Number of recent releases to check when inferring the version")
+	_ = cmd.MarkFlagRequired("source")
+
+	return cmd
+}
+
+// Run executes the adopt command.
+func Run(opts Options) error {
+	// Load registry and config.
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	path, err := filepath.Abs(opts.Path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("cannot adopt %s: %w", path, err)
+	}
+	if !fileInfo.Mode().IsRegular() {
+		return fmt.Errorf("cannot adopt %s: not a regular file", path)
+	}
+
+	name := filepath.Base(path)
+	if existing, found := reg.Get(name); found {
+		return fmt.Errorf("%s is already managed by execman (installed at %s)", name, existing.Path)
+	}
+
+	// Parse source.
+	owner, repo, version, err := github.ParseSource(opts.Source)
+	if err != nil {
+		return err
+	}
+	if opts.Version != "" {
+		version = opts.Version
+	}
+
+	// Adopting is a trust decision, but it must still respect the policy.
+	if err := policy.Enforce(github.ToURL(owner, repo)); err != nil {
+		return err
+	}
+
+	repository, err := github.GetRepository(owner, repo)
+	if err != nil {
+		return err
+	}
+	if repository.Changed(owner, repo, 0) {
+		confirmed, err := install.ConfirmMoved(owner, repo, 0, repository, "adopting "+name, opts.Yes, opts.AllowMoved)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Adoption cancelled.")
+			return nil
+		}
+		owner, repo = repository.Owner(), repository.Name()
+		if err := policy.Enforce(github.ToURL(owner, repo)); err != nil {
+			return err
+		}
+	}
+
+	checksum, err := archive.CalculateChecksum(path)
+	if err != nil {
+		return fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+
+	entry := &registry.Executable{
+		Source:      github.ToURL(owner, repo),
+		Version:     version,
+		InstalledAt: time.Now(),
+		Path:        path,
+		Platform:    fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH),
+		Checksum:    checksum,
+		RepoID:      repository.ID,
+	}
+
+	if entry.Version == "" {
+		if err := identify(entry, name, owner, repo, cfg, opts.SearchReleases); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\nAdoption Details:\n")
+	fmt.Printf("  Path:       %s\n", entry.Path)
+	fmt.Printf("  Name:       %s\n", name)
+	fmt.Printf("  Repository: %s\n", entry.Source)
+	fmt.Printf("  Version:    %s\n", entry.Version)
+	fmt.Printf("  Checksum:   %s\n", entry.Checksum)
+
+	if !opts.Yes {
+		fmt.Print("\nAdopt this executable? (Y/n): ")
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response == "n" || response == "no" {
+			fmt.Println("Adoption cancelled.")
+			return nil
+		}
+	}
+
+	if err := registry.Update(func(r *registry.Registry) error {
+		if existing, found := r.Get(name); found {
+			return fmt.Errorf("%s is already managed by execman (installed at %s)", name, existing.Path)
+		}
+		r.Add(name, entry)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to save registry: %w", err)
+	}
+
+	fmt.Printf("\n✓ Adopted %s %s\n", name, entry.Version)
+	if entry.Version == registry.UnknownVersion {
+		fmt.Println("The version could not be determined; the next update will install the latest release.")
+	}
+	return nil
+}
+
+// identify looks for the release whose binary for this platform matches the
+// entry's checksum, downloading and extracting the assets of up to limit
+// recent releases. Each binary is extracted under name, the executable's own
+// name, since an archive without permissions is searched for a file of that
+// name. On a match it fills in the version and asset provenance; otherwise
+// the version is recorded as unknown.
+func identify(entry *registry.Executable, name, owner, repo string, cfg *config.Config, limit int) error {
+	releases, err := github.ListReleases(owner, repo)
+	if err != nil {
+		return err
+	}
+	if len(releases) > limit {
+		releases = releases[:limit]
+	}
+
+	tempDir, err := os.MkdirTemp("", "execman-adopt-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for i := range releases {
+		release := &releases[i]
+		asset, err := github.FindAsset(release.Assets, runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			continue
+		}
+
+		fmt.Printf("Comparing with %s (%s)...\n", release.TagName, asset.Name)
+		releaseDir := filepath.Join(tempDir, fmt.Sprintf("%d", i))
+		if err := os.Mkdir(releaseDir, 0700); err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		archivePath := filepath.Join(releaseDir, asset.Name)
+		if err := github.DownloadAsset(asset, archivePath); err != nil {
+			fmt.Printf("  Skipping: %v\n", err)
+			continue
+		}
+		stagedDir := filepath.Join(releaseDir, "staged")
+		if err := os.Mkdir(stagedDir, 0700); err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		binaryPath := filepath.Join(stagedDir, name)
+		if err := archive.ExtractBinaryWithLimits(archivePath, binaryPath, cfg.ExtractLimits()); err != nil {
+			fmt.Printf("  Skipping: %v\n", err)
+			continue
+		}
+		checksum, err := archive.CalculateChecksum(binaryPath)
+		if err != nil || checksum != entry.Checksum {
+			continue
+		}
+
+		// The file is byte-for-byte this release's binary, so its
+		// provenance is known too.
+		provenance, err := install.VerifyDownload(release, asset, archivePath, releaseDir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Matched release %s.\n", release.TagName)
+		entry.Version = release.TagName
+		entry.AssetName = asset.Name
+		entry.AssetURL = asset.BrowserDownloadURL
+		entry.ArchiveChecksum = provenance.ArchiveChecksum
+		entry.ChecksumSource = provenance.ChecksumSource
+		entry.DownloadedAt = provenance.DownloadedAt
+		return nil
+	}
+
+	fmt.Printf("No match among the %d most recent releases.\n", len(releases))
+	entry.Version = registry.UnknownVersion
+	return nil
+}
@@ -0,0 +1,245 @@
+package adopt
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+// testAssetName is the name of each test release's asset for this platform.
+var testAssetName = "tool_" + runtime.GOOS + "_" + runtime.GOARCH + ".zip"
+
+// zipBinary returns a zip archive holding a README and a binary named tool
+// with content. Unless mode is zero, the binary has that mode.
+func zipBinary(t *testing.T, content string, mode os.FileMode) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(header *zip.FileHeader, content string) {
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("failed to add %s: %v", header.Name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write %s: %v", header.Name, err)
+		}
+	}
+	add(&zip.FileHeader{Name: "README.md"}, "read me\n")
+	header := &zip.FileHeader{Name: "tool"}
+	if mode != 0 {
+		header.SetMode(mode)
+	}
+	add(header, content)
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// fakeGitHub serves any repository of acme, such as acme/tool, with releases
+// v1.2.0, v1.1.0 and v1.0.0, newest first, whose binaries are named tool and
+// hold "new", "mid" and "old". The v1.1.0 archive records no permissions, as
+// zip files made on Windows do not. acme/oldtool has been renamed acme/tool.
+func fakeGitHub(t *testing.T) {
+	t.Helper()
+	assets := map[string][]byte{
+		"v1.2.0": zipBinary(t, "new", 0755),
+		"v1.1.0": zipBinary(t, "mid", 0),
+		"v1.0.0": zipBinary(t, "old", 0755),
+	}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repo, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/repos/acme/"), "/")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/repos/acme/") && rest == "":
+			if repo == "oldtool" {
+				repo = "tool"
+			}
+			_, _ = fmt.Fprintf(w, `{"id": 7, "full_name": "acme/%s"}`, repo)
+		case strings.HasPrefix(r.URL.Path, "/repos/acme/") && rest == "releases":
+			var releases []string
+			for _, tag := range []string{"v1.2.0", "v1.1.0", "v1.0.0"} {
+				releases = append(releases, fmt.Sprintf(`{"tag_name": %q, "assets": [{"name": %q, "browser_download_url": "%s/download/%s"}]}`,
+					tag, testAssetName, server.URL, tag))
+			}
+			_, _ = w.Write([]byte("[" + strings.Join(releases, ",") + "]"))
+		case strings.HasPrefix(r.URL.Path, "/download/"):
+			asset, found := assets[strings.TrimPrefix(r.URL.Path, "/download/")]
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(asset)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	savedBaseURL := github.APIBaseURL
+	github.APIBaseURL = server.URL
+	t.Cleanup(func() { github.APIBaseURL = savedBaseURL })
+}
+
+// setup isolates the config and registry and writes an executable named
+// tool with content, returning its path.
+func setup(t *testing.T, content string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	path := filepath.Join(home, "bin", "tool")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create bin directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		source      string
+		version     string
+		search      int
+		wantVersion string
+		wantAsset   bool // Whether the asset provenance is recorded.
+	}{
+		{name: "file named differently from the repository", content: "mid", source: "github.com/acme/toolkit", search: 5, wantVersion: "v1.1.0", wantAsset: true},
+		{name: "version flag", content: "custom", source: "github.com/acme/tool", version: "v0.9.0", search: 5, wantVersion: "v0.9.0"},
+		{name: "version in source", content: "custom", source: "github.com/acme/tool@v0.8.0", search: 5, wantVersion: "v0.8.0"},
+		{name: "version flag overrides source", content: "custom", source: "github.com/acme/tool@v0.8.0", version: "v0.9.0", search: 5, wantVersion: "v0.9.0"},
+		{name: "latest release", content: "new", source: "github.com/acme/tool", search: 5, wantVersion: "v1.2.0", wantAsset: true},
+		{name: "zip without permissions", content: "mid", source: "github.com/acme/tool", search: 5, wantVersion: "v1.1.0", wantAsset: true},
+		{name: "oldest release", content: "old", source: "github.com/acme/tool", search: 5, wantVersion: "v1.0.0", wantAsset: true},
+		{name: "beyond search limit", content: "old", source: "github.com/acme/tool", search: 2, wantVersion: registry.UnknownVersion},
+		{name: "no match", content: "custom", source: "github.com/acme/tool", search: 5, wantVersion: registry.UnknownVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeGitHub(t)
+			path := setup(t, tt.content)
+
+			err := Run(Options{Path: path, Source: tt.source, Version: tt.version, Yes: true, SearchReleases: tt.search})
+			if err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+
+			reg, err := registry.Load()
+			if err != nil {
+				t.Fatalf("failed to load registry: %v", err)
+			}
+			entry, found := reg.Get("tool")
+			if !found {
+				t.Fatal("tool is not in the registry")
+			}
+			if entry.Version != tt.wantVersion {
+				t.Errorf("Version = %q, want %q", entry.Version, tt.wantVersion)
+			}
+			wantSource := "https://" + strings.Split(tt.source, "@")[0]
+			if entry.Source != wantSource || entry.RepoID != 7 || entry.Path != path {
+				t.Errorf("registry records %s (id %d) at %s", entry.Source, entry.RepoID, entry.Path)
+			}
+			if entry.Checksum == "" {
+				t.Error("Checksum is not recorded")
+			}
+			if gotAsset := entry.AssetName != ""; gotAsset != tt.wantAsset {
+				t.Errorf("AssetName = %q, want provenance recorded: %v", entry.AssetName, tt.wantAsset)
+			}
+			if tt.wantAsset && (entry.AssetName != testAssetName || entry.ArchiveChecksum == "") {
+				t.Errorf("provenance = %s (%s), want %s", entry.AssetName, entry.ArchiveChecksum, testAssetName)
+			}
+		})
+	}
+}
+
+func TestRunRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       func(path string) string
+		source     string
+		adoptFirst bool
+	}{
+		{name: "missing file", path: func(path string) string { return path + "-missing" }, source: "github.com/acme/tool"},
+		{name: "directory", path: filepath.Dir, source: "github.com/acme/tool"},
+		{name: "bad source", path: func(path string) string { return path }, source: "not a source"},
+		{name: "already managed", path: func(path string) string { return path }, source: "github.com/acme/tool", adoptFirst: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeGitHub(t)
+			path := setup(t, "new")
+			if tt.adoptFirst {
+				if err := Run(Options{Path: path, Source: tt.source, Version: "v1.2.0", Yes: true}); err != nil {
+					t.Fatalf("first Run returned error: %v", err)
+				}
+			}
+
+			if err := Run(Options{Path: tt.path(path), Source: tt.source, Version: "v1.2.0", Yes: true}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRunMovedRepository(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowMoved bool
+		wantErr    bool
+	}{
+		{name: "refused with yes", wantErr: true},
+		{name: "allowed", allowMoved: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeGitHub(t)
+			path := setup(t, "new")
+
+			err := Run(Options{Path: path, Source: "github.com/acme/oldtool", Yes: true, AllowMoved: tt.allowMoved, SearchReleases: 5})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "--allow-moved") {
+					t.Fatalf("expected an error suggesting --allow-moved, got %v", err)
+				}
+				reg, err := registry.Load()
+				if err != nil {
+					t.Fatalf("failed to load registry: %v", err)
+				}
+				if _, found := reg.Get("tool"); found {
+					t.Error("tool was adopted from a moved repository")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+			reg, err := registry.Load()
+			if err != nil {
+				t.Fatalf("failed to load registry: %v", err)
+			}
+			entry, found := reg.Get("tool")
+			if !found {
+				t.Fatal("tool is not in the registry")
+			}
+			if entry.Source != "https://github.com/acme/tool" || entry.Version != "v1.2.0" {
+				t.Errorf("registry records %s %s, want https://github.com/acme/tool v1.2.0", entry.Source, entry.Version)
+			}
+		})
+	}
+}
@@ -2,18 +2,83 @@ package archive
 
 import (
 	"archive/tar"
+	"archive/zip"
+	"bytes"
 	"compress/gzip"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
 
-// ExtractBinary extracts a binary from a tar.gz archive.
+// Default extraction limits. Release binaries are rarely more than a few
+// hundred megabytes, so these leave plenty of headroom while still stopping a
+// decompression bomb long before it fills the disk.
+const (
+	DefaultMaxFileSize  int64 = 512 << 20 // 512 MiB
+	DefaultMaxTotalSize int64 = 2 << 30   // 2 GiB
+)
+
+// ErrFileTooLarge is returned when an archive entry exceeds Limits.MaxFileSize.
+var ErrFileTooLarge = errors.New("extracted file exceeds size limit")
+
+// ErrArchiveTooLarge is returned when the decompressed archive exceeds
+// Limits.MaxTotalSize.
+var ErrArchiveTooLarge = errors.New("archive expands beyond size limit")
+
+// Limits bounds how much data extraction may produce. A zero field means the
+// corresponding default.
+type Limits struct {
+	MaxFileSize  int64 // Maximum size of the extracted binary.
+	MaxTotalSize int64 // Maximum number of bytes decompressed from the archive.
+}
+
+// withDefaults returns a copy of the limits with zero fields replaced by the
+// defaults.
+func (l Limits) withDefaults() Limits {
+	if l.MaxFileSize <= 0 {
+		l.MaxFileSize = DefaultMaxFileSize
+	}
+	if l.MaxTotalSize <= 0 {
+		l.MaxTotalSize = DefaultMaxTotalSize
+	}
+	return l
+}
+
+// limitedReader counts the bytes read through it and fails with
+// ErrArchiveTooLarge once more than limit bytes have been read.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	if lr.read > lr.limit {
+		return n, fmt.Errorf("%w (limit %d bytes)", ErrArchiveTooLarge, lr.limit)
+	}
+	return n, err
+}
+
+// ExtractBinary extracts a binary from an archive using the default limits.
 func ExtractBinary(archivePath, destPath string) error {
+	return ExtractBinaryWithLimits(archivePath, destPath, Limits{})
+}
+
+// ExtractBinaryWithLimits extracts a binary from a tar.gz or zip archive, or
+// copies a release asset that is itself an executable, aborting with
+// ErrFileTooLarge or ErrArchiveTooLarge if the limits are exceeded. On
+// failure no partial file is left at destPath.
+func ExtractBinaryWithLimits(archivePath, destPath string, limits Limits) error {
+	limits = limits.withDefaults()
+
 	// Open the archive.
 	// #nosec G304 -- Opening archive in temp directory
 	file, err := os.Open(archivePath)
@@ -22,6 +87,53 @@ func ExtractBinary(archivePath, destPath string) error {
 	}
 	defer file.Close()
 
+	// Release assets are named inconsistently, so go by their contents.
+	magic := make([]byte, 4)
+	n, err := io.ReadFull(file, magic)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	magic = magic[:n]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return extractTarGz(file, destPath, limits)
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		return extractZip(file, info.Size(), destPath, limits)
+	case isExecutable(magic):
+		return writeBinary(destPath, filepath.Base(archivePath), file, limits.MaxFileSize)
+	default:
+		return fmt.Errorf("unsupported archive format: expected tar.gz, zip or an executable")
+	}
+}
+
+// isExecutable reports whether a file starting with magic is an executable
+// in one of the formats execman installs: ELF, Mach-O, PE or a script.
+func isExecutable(magic []byte) bool {
+	for _, prefix := range [][]byte{
+		[]byte("\x7fELF"),
+		{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf}, // Mach-O, big-endian
+		{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe}, // Mach-O, little-endian
+		{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal
+		[]byte("MZ"),
+		[]byte("#!"),
+	} {
+		if bytes.HasPrefix(magic, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractTarGz extracts the first executable file in a tar.gz archive.
+func extractTarGz(file io.Reader, destPath string, limits Limits) error {
 	// Create gzip reader.
 	gzr, err := gzip.NewReader(file)
 	if err != nil {
@@ -29,28 +141,20 @@ func ExtractBinary(archivePath, destPath string) error {
 	}
 	defer gzr.Close()
 
-	// Create tar reader.
-	tr := tar.NewReader(gzr)
-
-	// Get the directory of the destination to create a root scope.
-	destDir := filepath.Dir(destPath)
-	destName := filepath.Base(destPath)
-
-	// Create a scoped root for the destination directory to prevent path traversal.
-	root, err := os.OpenRoot(destDir)
-	if err != nil {
-		return fmt.Errorf("failed to create root scope: %w", err)
-	}
-	defer root.Close()
+	// Create tar reader over a counting reader, so that the total expansion
+	// includes skipped entries and tar padding, not just the binary.
+	tr := tar.NewReader(&limitedReader{r: gzr, limit: limits.MaxTotalSize})
 
 	// Find and extract the first executable file.
-	var extracted bool
 	for {
 		header, err := tr.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
+			if errors.Is(err, ErrArchiveTooLarge) {
+				return err
+			}
 			return fmt.Errorf("failed to read tar header: %w", err)
 		}
 
@@ -61,27 +165,101 @@ func ExtractBinary(archivePath, destPath string) error {
 
 		// Check if file is executable.
 		if header.Mode&0111 != 0 {
-			// Extract this file using scoped root.
-			destFile, err := root.OpenFile(destName, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0755)
-			if err != nil {
-				return fmt.Errorf("failed to create destination file: %w", err)
+			// Reject oversized entries before writing anything. The header
+			// size is what tar will produce, including sparse holes.
+			if header.Size > limits.MaxFileSize {
+				return fmt.Errorf("%w: %s is %d bytes (limit %d bytes)", ErrFileTooLarge, header.Name, header.Size, limits.MaxFileSize)
 			}
-			defer destFile.Close()
+			return writeBinary(destPath, header.Name, tr, limits.MaxFileSize)
+		}
+	}
 
-			// #nosec G110 -- Decompression of trusted GitHub release assets
-			if _, err := io.Copy(destFile, tr); err != nil {
-				return fmt.Errorf("failed to extract file: %w", err)
-			}
+	return fmt.Errorf("no executable file found in archive")
+}
+
+// extractZip extracts the first executable file in a zip archive. Zip files
+// made on Windows carry no permissions, so failing that it takes the file
+// named after the destination.
+func extractZip(file io.ReaderAt, size int64, destPath string, limits Limits) error {
+	zr, err := zip.NewReader(file, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+
+	// The sizes in the directory are only claims, but they are enforced
+	// while copying, so a lying archive fails there instead.
+	var total uint64
+	for _, f := range zr.File {
+		total += f.UncompressedSize64
+	}
+	if total > uint64(limits.MaxTotalSize) {
+		return fmt.Errorf("%w (limit %d bytes)", ErrArchiveTooLarge, limits.MaxTotalSize)
+	}
 
-			extracted = true
+	destName := filepath.Base(destPath)
+	var chosen *zip.File
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		if f.Mode()&0111 != 0 {
+			chosen = f
 			break
 		}
+		base := path.Base(f.Name)
+		if chosen == nil && (base == destName || base == destName+".exe") {
+			chosen = f
+		}
 	}
-
-	if !extracted {
+	if chosen == nil {
 		return fmt.Errorf("no executable file found in archive")
 	}
+	if chosen.UncompressedSize64 > uint64(limits.MaxFileSize) {
+		return fmt.Errorf("%w: %s is %d bytes (limit %d bytes)", ErrFileTooLarge, chosen.Name, chosen.UncompressedSize64, limits.MaxFileSize)
+	}
+
+	rc, err := chosen.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s in archive: %w", chosen.Name, err)
+	}
+	defer rc.Close()
+	return writeBinary(destPath, chosen.Name, rc, limits.MaxFileSize)
+}
+
+// writeBinary copies at most maxSize bytes from r to destPath, made
+// executable. It writes through a root scoped to the destination directory
+// to prevent path traversal, and removes the file again on failure.
+func writeBinary(destPath, name string, r io.Reader, maxSize int64) (err error) {
+	root, err := os.OpenRoot(filepath.Dir(destPath))
+	if err != nil {
+		return fmt.Errorf("failed to create root scope: %w", err)
+	}
+	defer root.Close()
+
+	destName := filepath.Base(destPath)
+	destFile, err := root.OpenFile(destName, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer destFile.Close()
+	defer func() {
+		if err != nil {
+			_ = root.Remove(destName)
+		}
+	}()
 
+	// Copy at most one byte more than the limit, so that an oversized file
+	// is detected without writing all of it.
+	n, err := io.Copy(destFile, io.LimitReader(r, maxSize+1))
+	if err != nil {
+		if errors.Is(err, ErrArchiveTooLarge) {
+			return err
+		}
+		return fmt.Errorf("failed to extract file: %w", err)
+	}
+	if n > maxSize {
+		return fmt.Errorf("%w: %s (limit %d bytes)", ErrFileTooLarge, name, maxSize)
+	}
 	return nil
 }
 
@@ -0,0 +1,321 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// tarEntry describes a regular file to put in a test archive.
+type tarEntry struct {
+	name string
+	mode int64
+	data []byte
+}
+
+// buildTar returns an uncompressed tar stream containing the given entries.
+func buildTar(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(e.data))}); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			t.Fatalf("failed to write tar data: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// gzipBytes compresses data with gzip.
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	if _, err := gzw.Write(data); err != nil {
+		t.Fatalf("failed to gzip data: %v", err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// writeArchive writes the archive to a temp directory and returns its path
+// together with a destination path for the extracted binary.
+func writeArchive(t *testing.T, data []byte) (archivePath, destPath string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	archivePath = filepath.Join(tmpDir, "tool.tar.gz")
+	if err := os.WriteFile(archivePath, data, 0600); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	return archivePath, filepath.Join(tmpDir, "tool")
+}
+
+// rawHeader builds a USTAR header block by hand. The tar package refuses to
+// write some of the headers these tests need, such as GNU sparse PAX records.
+func rawHeader(name string, typeflag byte, mode, size int64) []byte {
+	b := make([]byte, 512)
+	copy(b[0:100], name)
+	copy(b[100:108], fmt.Sprintf("%07o\x00", mode))
+	copy(b[108:116], "0000000\x00")
+	copy(b[116:124], "0000000\x00")
+	copy(b[124:136], fmt.Sprintf("%011o\x00", size))
+	copy(b[136:148], "00000000000\x00")
+	b[156] = typeflag
+	copy(b[257:265], "ustar\x0000")
+
+	// The checksum is calculated with the checksum field filled with spaces.
+	copy(b[148:156], "        ")
+	sum := 0
+	for _, c := range b {
+		sum += int(c)
+	}
+	copy(b[148:156], fmt.Sprintf("%06o\x00 ", sum))
+	return b
+}
+
+// paxRecord formats a PAX record, whose length prefix counts itself.
+func paxRecord(key, value string) string {
+	body := " " + key + "=" + value + "\n"
+	n := len(body)
+	for len(strconv.Itoa(n))+len(body) != n {
+		n = len(strconv.Itoa(n)) + len(body)
+	}
+	return strconv.Itoa(n) + body
+}
+
+// padBlock pads data to a multiple of the tar block size.
+func padBlock(data []byte) []byte {
+	if r := len(data) % 512; r != 0 {
+		data = append(data, make([]byte, 512-r)...)
+	}
+	return data
+}
+
+func TestExtractBinary(t *testing.T) {
+	payload := []byte("#!/bin/sh\necho hello\n")
+	archivePath, destPath := writeArchive(t, gzipBytes(t, buildTar(t, []tarEntry{
+		{name: "README.md", mode: 0644, data: []byte("readme")},
+		{name: "tool", mode: 0755, data: payload},
+	})))
+
+	if err := ExtractBinary(archivePath, destPath); err != nil {
+		t.Fatalf("ExtractBinary returned error: %v", err)
+	}
+
+	// #nosec G304 -- Reading file created by the test
+	got, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("failed to read extracted file: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("extracted %q, want %q", got, payload)
+	}
+}
+
+func TestExtractBinaryRejectsHugeSparseEntry(t *testing.T) {
+	// A GNU sparse 1.0 entry occupies a couple of blocks on disk but expands
+	// to 10 GiB, almost all of it holes.
+	const realSize = 10 << 30
+	records := paxRecord("GNU.sparse.major", "1") +
+		paxRecord("GNU.sparse.minor", "0") +
+		paxRecord("GNU.sparse.name", "tool") +
+		paxRecord("GNU.sparse.realsize", strconv.Itoa(realSize))
+	sparseMap := fmt.Sprintf("1\n%d\n1\n", realSize-1)
+
+	var tarData bytes.Buffer
+	tarData.Write(rawHeader("PaxHeaders/tool", tar.TypeXHeader, 0644, int64(len(records))))
+	tarData.Write(padBlock([]byte(records)))
+	tarData.Write(rawHeader("tool", tar.TypeReg, 0755, 513))
+	tarData.Write(padBlock([]byte(sparseMap)))
+	tarData.Write(padBlock([]byte("x")))
+	tarData.Write(make([]byte, 1024))
+
+	archivePath, destPath := writeArchive(t, gzipBytes(t, tarData.Bytes()))
+
+	err := ExtractBinaryWithLimits(archivePath, destPath, Limits{MaxFileSize: 1 << 20})
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected ErrFileTooLarge, got %v", err)
+	}
+	if _, statErr := os.Stat(destPath); !os.IsNotExist(statErr) {
+		t.Errorf("expected no file at %s after failure", destPath)
+	}
+}
+
+func TestExtractBinaryRejectsOversizedFile(t *testing.T) {
+	archivePath, destPath := writeArchive(t, gzipBytes(t, buildTar(t, []tarEntry{
+		{name: "tool", mode: 0755, data: make([]byte, 2<<20)},
+	})))
+
+	err := ExtractBinaryWithLimits(archivePath, destPath, Limits{MaxFileSize: 1 << 20})
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected ErrFileTooLarge, got %v", err)
+	}
+	if _, statErr := os.Stat(destPath); !os.IsNotExist(statErr) {
+		t.Errorf("expected no file at %s after failure", destPath)
+	}
+}
+
+func TestExtractBinaryRejectsArchiveExpansion(t *testing.T) {
+	// Highly compressible padding ahead of the binary counts towards the
+	// total expansion even though it is never written to disk.
+	archivePath, destPath := writeArchive(t, gzipBytes(t, buildTar(t, []tarEntry{
+		{name: "padding-1", mode: 0644, data: make([]byte, 4<<20)},
+		{name: "padding-2", mode: 0644, data: make([]byte, 4<<20)},
+		{name: "tool", mode: 0755, data: []byte("binary")},
+	})))
+
+	err := ExtractBinaryWithLimits(archivePath, destPath, Limits{MaxTotalSize: 6 << 20})
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("expected ErrArchiveTooLarge, got %v", err)
+	}
+	if _, statErr := os.Stat(destPath); !os.IsNotExist(statErr) {
+		t.Errorf("expected no file at %s after failure", destPath)
+	}
+}
+
+func TestExtractBinaryRejectsConcatenatedGzipMembers(t *testing.T) {
+	// A gzip stream may consist of many members, each expanding separately,
+	// so the limit must apply across members rather than to each one.
+	tarData := buildTar(t, []tarEntry{
+		{name: "padding", mode: 0644, data: make([]byte, 8<<20)},
+		{name: "tool", mode: 0755, data: []byte("binary")},
+	})
+	var data []byte
+	for len(tarData) > 0 {
+		chunk := tarData[:min(len(tarData), 1<<20)]
+		tarData = tarData[len(chunk):]
+		data = append(data, gzipBytes(t, chunk)...)
+	}
+	archivePath, destPath := writeArchive(t, data)
+
+	err := ExtractBinaryWithLimits(archivePath, destPath, Limits{MaxTotalSize: 4 << 20})
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("expected ErrArchiveTooLarge, got %v", err)
+	}
+}
+
+func TestExtractBinaryRejectsNestedGzip(t *testing.T) {
+	// Each layer of a deeply nested gzip expands to the next compressed layer.
+	// Only one layer is ever decompressed, so the innermost 64 MiB is never
+	// produced and the archive is rejected as malformed.
+	data := buildTar(t, []tarEntry{{name: "tool", mode: 0755, data: make([]byte, 64<<20)}})
+	for range 10 {
+		data = gzipBytes(t, data)
+	}
+	archivePath, destPath := writeArchive(t, data)
+
+	err := ExtractBinaryWithLimits(archivePath, destPath, Limits{MaxFileSize: 1 << 20, MaxTotalSize: 1 << 20})
+	if err == nil {
+		t.Fatal("expected an error for a nested gzip archive")
+	}
+	if _, statErr := os.Stat(destPath); !os.IsNotExist(statErr) {
+		t.Errorf("expected no file at %s after failure", destPath)
+	}
+}
+
+// buildZip returns a zip archive containing the given entries.
+func buildZip(t *testing.T, entries []tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			header.SetMode(os.FileMode(e.mode))
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("failed to write zip header: %v", err)
+		}
+		if _, err := w.Write(e.data); err != nil {
+			t.Fatalf("failed to write zip data: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractBinaryFormats(t *testing.T) {
+	payload := []byte("\x7fELF binary")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{
+			name: "zip with permissions",
+			data: buildZip(t, []tarEntry{
+				{name: "LICENSE.txt", mode: 0644, data: []byte("license")},
+				{name: "bin/tool", mode: 0755, data: payload},
+			}),
+		},
+		{
+			name: "zip without permissions",
+			data: buildZip(t, []tarEntry{
+				{name: "README", data: []byte("readme")},
+				{name: "tool.exe", data: payload},
+			}),
+		},
+		{
+			name:    "zip without executable",
+			data:    buildZip(t, []tarEntry{{name: "README", data: []byte("readme")}}),
+			wantErr: true,
+		},
+		{name: "raw executable", data: payload},
+		{name: "unsupported", data: []byte("plain text"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archivePath, destPath := writeArchive(t, tt.data)
+			err := ExtractBinary(archivePath, destPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExtractBinary returned error: %v", err)
+			}
+			// #nosec G304 -- Reading file created by the test
+			got, err := os.ReadFile(destPath)
+			if err != nil {
+				t.Fatalf("failed to read extracted file: %v", err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("extracted %q, want %q", got, payload)
+			}
+		})
+	}
+}
+
+func TestExtractBinaryRejectsZipExpansion(t *testing.T) {
+	archivePath, destPath := writeArchive(t, buildZip(t, []tarEntry{
+		{name: "padding", mode: 0644, data: make([]byte, 2<<20)},
+		{name: "tool", mode: 0755, data: []byte("\x7fELF")},
+	}))
+
+	err := ExtractBinaryWithLimits(archivePath, destPath, Limits{MaxTotalSize: 1 << 20})
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("expected ErrArchiveTooLarge, got %v", err)
+	}
+}
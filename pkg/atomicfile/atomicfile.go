@@ -0,0 +1,271 @@
+// Package atomicfile writes files so that readers, and the file itself after
+// a crash, only ever see the complete old contents or the complete new ones.
+package atomicfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// BackupSuffix is appended to a file's path to name its backup.
+const BackupSuffix = ".bak"
+
+// WriteFile writes data to a temporary file in the same directory as path,
+// flushes it to disk and renames it over path.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	return writeFile(path, data, perm, false)
+}
+
+// WriteFileWithBackup is like WriteFile but first preserves the current
+// contents of path, if any, as path+BackupSuffix.
+func WriteFileWithBackup(path string, data []byte, perm os.FileMode) error {
+	return writeFile(path, data, perm, true)
+}
+
+func writeFile(path string, data []byte, perm os.FileMode, backup bool) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to flush temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	if backup {
+		if err := backupFile(path); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// backupFile copies path to path+BackupSuffix, replacing any older backup.
+// The copy is itself written atomically so that the backup is never torn.
+func backupFile(path string) error {
+	// #nosec G304 -- Backing up execman's own files
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s for backup: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s for backup: %w", path, err)
+	}
+	if err := WriteFile(path+BackupSuffix, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// syncDir flushes a directory so that a rename within it survives a crash.
+// Not every platform supports syncing directories, so failure is ignored.
+func syncDir(dir string) {
+	// #nosec G304 -- Opening a directory we just wrote into
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+// Replacement is an executable installed by ReplaceExecutable. Until it is
+// committed, the previous file can be put back with Restore.
+type Replacement struct {
+	path     string
+	previous string // Empty if there was no previous file.
+}
+
+// ReplaceExecutable installs src at dst with executable permissions. The new
+// file is staged in dst's directory and renamed over dst, so dst is never
+// missing or partially written, and processes already running the old binary
+// keep their inode. The previous file is kept until Commit or Restore.
+func ReplaceExecutable(src, dst string) (_ *Replacement, err error) {
+	// #nosec G304 -- Reading a binary staged by execman
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	dir := filepath.Dir(dst)
+	staged, err := os.CreateTemp(dir, "."+filepath.Base(dst)+".execman-new-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage new executable: %w", err)
+	}
+	stagedPath := staged.Name()
+	defer func() {
+		if err != nil {
+			_ = staged.Close()
+			_ = os.Remove(stagedPath)
+		}
+	}()
+
+	if _, err := io.Copy(staged, in); err != nil {
+		return nil, fmt.Errorf("failed to stage new executable: %w", err)
+	}
+	// #nosec G302 -- Executables need 0755 permissions
+	if err := staged.Chmod(0755); err != nil {
+		return nil, fmt.Errorf("failed to set executable permissions: %w", err)
+	}
+	if err := staged.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to flush new executable: %w", err)
+	}
+	if err := staged.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close new executable: %w", err)
+	}
+
+	r := &Replacement{path: dst}
+	if _, statErr := os.Lstat(dst); statErr == nil {
+		r.previous, err = preserve(dst)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.Rename(stagedPath, dst); err != nil {
+		if r.previous != "" {
+			_ = os.Remove(r.previous)
+		}
+		return nil, fmt.Errorf("failed to replace %s: %w", dst, err)
+	}
+	syncDir(dir)
+	return r, nil
+}
+
+// ReplaceSymlink points link at target. The new symlink is created under a
+// temporary name in link's directory and renamed over link, so link always
+// resolves to either the old or the new target. Whatever was at link before,
+// symlink or file, is kept until Commit or Restore.
+func ReplaceSymlink(target, link string) (*Replacement, error) {
+	dir := filepath.Dir(link)
+
+	// Reserve a unique name, then put the symlink in its place.
+	placeholder, err := os.CreateTemp(dir, "."+filepath.Base(link)+".execman-link-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage symlink: %w", err)
+	}
+	stagedPath := placeholder.Name()
+	_ = placeholder.Close()
+	if err := os.Remove(stagedPath); err != nil {
+		return nil, fmt.Errorf("failed to stage symlink: %w", err)
+	}
+	if err := os.Symlink(target, stagedPath); err != nil {
+		return nil, fmt.Errorf("failed to stage symlink: %w", err)
+	}
+
+	r := &Replacement{path: link}
+	if fileInfo, statErr := os.Lstat(link); statErr == nil {
+		if fileInfo.Mode()&os.ModeSymlink != 0 {
+			r.previous, err = preserveSymlink(link)
+		} else {
+			r.previous, err = preserve(link)
+		}
+		if err != nil {
+			_ = os.Remove(stagedPath)
+			return nil, err
+		}
+	}
+
+	if err := os.Rename(stagedPath, link); err != nil {
+		_ = os.Remove(stagedPath)
+		if r.previous != "" {
+			_ = os.Remove(r.previous)
+		}
+		return nil, fmt.Errorf("failed to replace %s: %w", link, err)
+	}
+	syncDir(dir)
+	return r, nil
+}
+
+// preserveSymlink keeps a copy of the symlink at path under a new name in
+// the same directory.
+func preserveSymlink(path string) (string, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to preserve previous symlink: %w", err)
+	}
+	previous := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".execman-old")
+	_ = os.Remove(previous)
+	if err := os.Symlink(target, previous); err != nil {
+		return "", fmt.Errorf("failed to preserve previous symlink: %w", err)
+	}
+	return previous, nil
+}
+
+// preserve keeps the current file at path under a new name in the same
+// directory, preferring a hard link so that the inode is shared.
+func preserve(path string) (string, error) {
+	previous := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".execman-old")
+	_ = os.Remove(previous)
+	if err := os.Link(path, previous); err == nil {
+		return previous, nil
+	}
+
+	// Some filesystems do not support hard links, so fall back to a copy.
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to preserve previous executable: %w", err)
+	}
+	// #nosec G304 -- Reading the executable being replaced
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to preserve previous executable: %w", err)
+	}
+	if err := WriteFile(previous, data, info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to preserve previous executable: %w", err)
+	}
+	return previous, nil
+}
+
+// Commit discards the previous file, making the replacement permanent.
+func (r *Replacement) Commit() error {
+	if r.previous == "" {
+		return nil
+	}
+	if err := os.Remove(r.previous); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Restore puts the previous file back, or removes the new one if there was
+// no previous file.
+func (r *Replacement) Restore() error {
+	if r.previous == "" {
+		return os.Remove(r.path)
+	}
+	if err := os.Rename(r.previous, r.path); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", r.path, err)
+	}
+	syncDir(filepath.Dir(r.path))
+	return nil
+}
@@ -0,0 +1,224 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	// #nosec G304 -- Reading file created by the test
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestWriteFileWithBackup(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "registry.json")
+
+	if err := WriteFileWithBackup(path, []byte("first"), 0600); err != nil {
+		t.Fatalf("WriteFileWithBackup returned error: %v", err)
+	}
+	if _, err := os.Stat(path + BackupSuffix); !os.IsNotExist(err) {
+		t.Error("expected no backup when there was no previous version")
+	}
+
+	if err := WriteFileWithBackup(path, []byte("second"), 0600); err != nil {
+		t.Fatalf("WriteFileWithBackup returned error: %v", err)
+	}
+	if got := readFile(t, path); got != "second" {
+		t.Errorf("expected new contents, got %q", got)
+	}
+	if got := readFile(t, path+BackupSuffix); got != "first" {
+		t.Errorf("expected backup of previous contents, got %q", got)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", path, err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	// Only the file and its backup should remain; no temporary files.
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 files, found %d", len(entries))
+	}
+}
+
+func TestWriteFileLeavesOriginalOnFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.json")
+	if err := WriteFile(path, []byte("original"), 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+
+	// Renaming over a directory fails after the temporary file is written.
+	dirPath := filepath.Join(tmpDir, "occupied")
+	if err := os.Mkdir(dirPath, 0700); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dirPath, "child"), nil, 0600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := WriteFile(dirPath, []byte("new"), 0600); err == nil {
+		t.Fatal("expected error when replacing a directory")
+	}
+
+	if got := readFile(t, path); got != "original" {
+		t.Errorf("expected original contents, got %q", got)
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected temporary file to be cleaned up, found %d entries", len(entries))
+	}
+}
+
+func TestReplaceExecutable(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "staged")
+	dst := filepath.Join(tmpDir, "tool")
+	if err := os.WriteFile(src, []byte("new"), 0600); err != nil {
+		t.Fatalf("failed to write staged file: %v", err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0600); err != nil {
+		t.Fatalf("failed to write existing file: %v", err)
+	}
+
+	// A process running the old binary keeps it open.
+	// #nosec G304 -- Opening file created by the test
+	running, err := os.Open(dst)
+	if err != nil {
+		t.Fatalf("failed to open existing file: %v", err)
+	}
+	defer running.Close()
+
+	replacement, err := ReplaceExecutable(src, dst)
+	if err != nil {
+		t.Fatalf("ReplaceExecutable returned error: %v", err)
+	}
+	if got := readFile(t, dst); got != "new" {
+		t.Errorf("expected new contents, got %q", got)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", dst, err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected permissions 0755, got %o", info.Mode().Perm())
+	}
+	buf := make([]byte, 3)
+	if _, err := running.ReadAt(buf, 0); err != nil || string(buf) != "old" {
+		t.Errorf("expected open handle to still read old contents, got %q, %v", buf, err)
+	}
+
+	if err := replacement.Restore(); err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	if got := readFile(t, dst); got != "old" {
+		t.Errorf("expected old contents after restore, got %q", got)
+	}
+
+	replacement, err = ReplaceExecutable(src, dst)
+	if err != nil {
+		t.Fatalf("ReplaceExecutable returned error: %v", err)
+	}
+	if err := replacement.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected only the staged file and the executable, found %d entries", len(entries))
+	}
+}
+
+func TestReplaceExecutableRestoreWithoutPrevious(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "staged")
+	dst := filepath.Join(tmpDir, "tool")
+	if err := os.WriteFile(src, []byte("new"), 0600); err != nil {
+		t.Fatalf("failed to write staged file: %v", err)
+	}
+
+	replacement, err := ReplaceExecutable(src, dst)
+	if err != nil {
+		t.Fatalf("ReplaceExecutable returned error: %v", err)
+	}
+	if err := replacement.Restore(); err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Error("expected restore to remove a newly created executable")
+	}
+}
+
+func TestReplaceSymlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	v1 := filepath.Join(tmpDir, "v1")
+	v2 := filepath.Join(tmpDir, "v2")
+	link := filepath.Join(tmpDir, "tool")
+	for path, content := range map[string]string{v1: "v1", v2: "v2"} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	if err := os.Symlink(v1, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	r, err := ReplaceSymlink(v2, link)
+	if err != nil {
+		t.Fatalf("ReplaceSymlink returned error: %v", err)
+	}
+	if got := readFile(t, link); got != "v2" {
+		t.Errorf("expected link to resolve to v2, got %q", got)
+	}
+
+	if err := r.Restore(); err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != v1 {
+		t.Errorf("expected link restored to %s, got %q (%v)", v1, target, err)
+	}
+
+	// Replacing a regular file with a symlink keeps the file until commit.
+	if err := os.Remove(link); err != nil {
+		t.Fatalf("failed to remove link: %v", err)
+	}
+	if err := os.WriteFile(link, []byte("copy"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	r, err = ReplaceSymlink(v1, link)
+	if err != nil {
+		t.Fatalf("ReplaceSymlink returned error: %v", err)
+	}
+	if err := r.Commit(); err != nil {
+		t.Fatalf("Commit returned error: %v", err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != v1 {
+		t.Errorf("expected link to %s, got %q (%v)", v1, target, err)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("expected no leftover staging files, got %d entries", len(entries))
+	}
+}
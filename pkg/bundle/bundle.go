@@ -0,0 +1,236 @@
+// Package bundle packages release assets into a single tar file that can be
+// carried to hosts without internet access and installed there.
+package bundle
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestName is the name of the manifest inside a bundle. It is always the
+// first entry, so that a bundle can be read in a single pass.
+const ManifestName = "bundle.json"
+
+// CurrentVersion is the bundle format version written by this build.
+const CurrentVersion = 1
+
+// MaxManifestSize bounds the manifest read from a bundle.
+const MaxManifestSize = 1 << 20
+
+// Manifest describes the contents of a bundle.
+type Manifest struct {
+	Version   int       `json:"bundle_version"`
+	CreatedAt time.Time `json:"created_at"`
+	Entries   []*Entry  `json:"entries"`
+}
+
+// Entry is one release asset in a bundle, with everything needed to install
+// and register it as if it had been downloaded on the installing host.
+type Entry struct {
+	Name            string    `json:"name"`
+	Source          string    `json:"source"`
+	RepoID          int64     `json:"repo_id,omitempty"`
+	Version         string    `json:"version"`
+	Platform        string    `json:"platform"`
+	AssetName       string    `json:"asset_name"`
+	AssetURL        string    `json:"asset_url,omitempty"`
+	ArchiveChecksum string    `json:"archive_checksum"`
+	ChecksumSource  string    `json:"checksum_source,omitempty"`
+	Checksum        string    `json:"checksum"`
+	DownloadedAt    time.Time `json:"downloaded_at,omitzero"`
+	// File is the asset's path inside the bundle.
+	File string `json:"file"`
+}
+
+// FileName returns the path inside a bundle for an asset.
+func FileName(name, platform, assetName string) string {
+	return path.Join("assets", strings.ReplaceAll(platform, "/", "_"), name, assetName)
+}
+
+// ParsePlatform checks that a platform is written as os/arch.
+func ParsePlatform(platform string) (goos, goarch string, err error) {
+	goos, goarch, ok := strings.Cut(platform, "/")
+	if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+		return "", "", fmt.Errorf("invalid platform %q: expected os/arch, e.g. linux/amd64", platform)
+	}
+	return goos, goarch, nil
+}
+
+// Missing returns the names in the bundle that have no asset for platform,
+// in order.
+func (m *Manifest) Missing(platform string) []string {
+	found := make(map[string]bool)
+	for _, e := range m.Entries {
+		found[e.Name] = found[e.Name] || e.Platform == platform
+	}
+	var missing []string
+	for name, ok := range found {
+		if !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Write writes a bundle to outPath. files maps each entry's File to the local
+// path of its asset. The bundle is written to a temporary file first, so a
+// failure never leaves a truncated bundle behind.
+func Write(outPath string, m *Manifest, files map[string]string) (err error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), "."+filepath.Base(outPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	tw := tar.NewWriter(tmp)
+	if err := writeEntry(tw, ManifestName, data); err != nil {
+		return err
+	}
+	for _, e := range m.Entries {
+		localPath, ok := files[e.File]
+		if !ok {
+			return fmt.Errorf("no asset file for %s", e.File)
+		}
+		// #nosec G304 -- Reading an asset downloaded by execman
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return fmt.Errorf("failed to read asset: %w", err)
+		}
+		if err := writeEntry(tw, e.File, data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), outPath); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+// Read reads a bundle's manifest and extracts the assets for platform into
+// dir, returning the extracted path of each by its File. Paths inside the
+// bundle are only used to look entries up, never to name files on disk, and
+// assets for other platforms are skipped without being written.
+func Read(bundlePath, platform, dir string) (*Manifest, map[string]string, error) {
+	// #nosec G304 -- Reading the bundle named by the user
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer file.Close()
+
+	tr := tar.NewReader(file)
+	header, err := tr.Next()
+	if err != nil || header.Name != ManifestName {
+		return nil, nil, fmt.Errorf("not an execman bundle: %s is not the first entry", ManifestName)
+	}
+	data, err := io.ReadAll(io.LimitReader(tr, MaxManifestSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read bundle manifest: %w", err)
+	}
+	if len(data) > MaxManifestSize {
+		return nil, nil, fmt.Errorf("bundle manifest is larger than %d bytes", MaxManifestSize)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+	if m.Version > CurrentVersion {
+		return nil, nil, fmt.Errorf("bundle version %d is newer than this execman supports (%d); upgrade execman", m.Version, CurrentVersion)
+	}
+
+	wanted := make(map[string]*Entry)
+	for _, e := range m.Entries {
+		if e.Platform == platform {
+			wanted[e.File] = e
+		}
+	}
+
+	extracted := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if _, ok := wanted[header.Name]; !ok || header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if _, done := extracted[header.Name]; done {
+			return nil, nil, fmt.Errorf("bundle contains %s more than once", header.Name)
+		}
+
+		localPath := filepath.Join(dir, fmt.Sprintf("asset-%d", len(extracted)))
+		if err := extractTo(tr, localPath); err != nil {
+			return nil, nil, err
+		}
+		extracted[header.Name] = localPath
+	}
+
+	for file := range wanted {
+		if _, ok := extracted[file]; !ok {
+			return nil, nil, fmt.Errorf("bundle is missing %s", file)
+		}
+	}
+	return &m, extracted, nil
+}
+
+func extractTo(r io.Reader, localPath string) error {
+	// #nosec G304 -- Writing into execman's own temp directory
+	out, err := os.OpenFile(localPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to extract asset: %w", err)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to extract asset: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to extract asset: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/policy"
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+// releaseArchive builds a tar.gz holding a single executable.
+func releaseArchive(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	if err := tw.WriteHeader(&tar.Header{Name: "tool", Mode: 0755, Size: int64(len(content))}); err != nil {
+		t.Fatalf("WriteHeader returned error: %v", err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// writeBundle writes a bundle holding one tool for each platform.
+func writeBundle(t *testing.T, dir string, platforms map[string]string) string {
+	t.Helper()
+	m := &Manifest{Version: CurrentVersion}
+	files := make(map[string]string)
+	for platform, content := range platforms {
+		assetPath := filepath.Join(dir, fmt.Sprintf("asset-%d.tar.gz", len(files)))
+		if err := os.WriteFile(assetPath, releaseArchive(t, content), 0600); err != nil {
+			t.Fatalf("WriteFile returned error: %v", err)
+		}
+		archiveChecksum, err := archive.CalculateChecksum(assetPath)
+		if err != nil {
+			t.Fatalf("CalculateChecksum returned error: %v", err)
+		}
+		binaryPath := filepath.Join(dir, "binary")
+		if err := archive.ExtractBinary(assetPath, binaryPath); err != nil {
+			t.Fatalf("ExtractBinary returned error: %v", err)
+		}
+		checksum, err := archive.CalculateChecksum(binaryPath)
+		if err != nil {
+			t.Fatalf("CalculateChecksum returned error: %v", err)
+		}
+		entry := &Entry{
+			Name:            "tool",
+			Source:          "https://github.com/acme/tool",
+			RepoID:          42,
+			Version:         "v1.0.0",
+			Platform:        platform,
+			AssetName:       "tool.tar.gz",
+			AssetURL:        "https://github.com/acme/tool/releases/download/v1.0.0/tool.tar.gz",
+			ArchiveChecksum: archiveChecksum,
+			Checksum:        checksum,
+			File:            FileName("tool", platform, "tool.tar.gz"),
+		}
+		m.Entries = append(m.Entries, entry)
+		files[entry.File] = assetPath
+	}
+
+	bundlePath := filepath.Join(dir, "bundle.tar")
+	if err := Write(bundlePath, m, files); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	return bundlePath
+}
+
+func TestReadExtractsOnlyPlatformAssets(t *testing.T) {
+	dir := t.TempDir()
+	bundlePath := writeBundle(t, dir, map[string]string{
+		"linux/amd64":  "linux binary",
+		"darwin/arm64": "darwin binary",
+	})
+
+	outDir := t.TempDir()
+	m, extracted, err := Read(bundlePath, "linux/amd64", outDir)
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if len(m.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(m.Entries))
+	}
+	if len(extracted) != 1 {
+		t.Fatalf("expected 1 extracted asset, got %v", extracted)
+	}
+	localPath := extracted[FileName("tool", "linux/amd64", "tool.tar.gz")]
+	if filepath.Dir(localPath) != outDir {
+		t.Errorf("asset extracted outside the output directory: %s", localPath)
+	}
+	binaryPath := filepath.Join(outDir, "binary")
+	if err := archive.ExtractBinary(localPath, binaryPath); err != nil {
+		t.Fatalf("ExtractBinary returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(binaryPath); string(data) != "linux binary" {
+		t.Errorf("extracted the wrong asset: %q", data)
+	}
+
+	if missing := m.Missing("linux/amd64"); len(missing) != 0 {
+		t.Errorf("expected nothing missing for linux/amd64, got %v", missing)
+	}
+	if missing := m.Missing("windows/amd64"); len(missing) != 1 || missing[0] != "tool" {
+		t.Errorf("expected tool missing for windows/amd64, got %v", missing)
+	}
+}
+
+func TestReadRejectsNonBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "other.tar")
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := writeEntry(tw, "README", []byte("hello")); err != nil {
+		t.Fatalf("writeEntry returned error: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	if _, _, err := Read(path, "linux/amd64", t.TempDir()); err == nil {
+		t.Fatal("expected an error for a tar file without a manifest")
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		wantErr  bool
+	}{
+		{platform: "linux/amd64"},
+		{platform: "darwin/arm64"},
+		{platform: "linux", wantErr: true},
+		{platform: "linux/", wantErr: true},
+		{platform: "linux/arm/v7", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, _, err := ParsePlatform(tt.platform)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePlatform(%q) error = %v, wantErr %v", tt.platform, err, tt.wantErr)
+		}
+	}
+}
+
+func TestInstallWithoutNetwork(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	savedBaseURL := github.APIBaseURL
+	github.APIBaseURL = server.URL
+	defer func() { github.APIBaseURL = savedBaseURL }()
+
+	savedPolicyPath := policy.SystemPolicyPath
+	policy.SystemPolicyPath = filepath.Join(t.TempDir(), "policy.json")
+	defer func() { policy.SystemPolicyPath = savedPolicyPath }()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	bundlePath := writeBundle(t, dir, map[string]string{
+		thisPlatform(): "bundled binary",
+	})
+
+	into := t.TempDir()
+	if err := Install(InstallOptions{Path: bundlePath, Into: into}); err != nil {
+		t.Fatalf("Install returned error: %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(into, "tool")); err != nil || string(data) != "bundled binary" {
+		t.Fatalf("expected the bundled binary to be installed, got %q (%v)", data, err)
+	}
+	reg, err := registry.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	exec, found := reg.Get("tool")
+	if !found {
+		t.Fatal("expected tool to be registered")
+	}
+	if exec.Source != "https://github.com/acme/tool" || exec.Version != "v1.0.0" || exec.RepoID != 42 ||
+		exec.AssetURL == "" || exec.Platform != thisPlatform() {
+		t.Errorf("unexpected registry entry %+v", exec)
+	}
+
+	// Installing again finds the same binary already in place.
+	if err := Install(InstallOptions{Path: bundlePath, Into: into}); err != nil {
+		t.Fatalf("second Install returned error: %v", err)
+	}
+}
+
+func thisPlatform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+func TestBundleKeepsRegistryName(t *testing.T) {
+	// gh is installed from acme/tool, so it must not come back as tool.
+	asset := releaseArchive(t, "gh binary")
+	assetName := "tool_" + runtime.GOOS + "_" + runtime.GOARCH + ".tar.gz"
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/tool":
+			_, _ = w.Write([]byte(`{"id": 42, "full_name": "acme/tool"}`))
+		case "/repos/acme/tool/releases/tags/v1.0.0":
+			_, _ = fmt.Fprintf(w, `{"tag_name": "v1.0.0", "assets": [{"name": %q, "browser_download_url": "%s/download/asset"}]}`, assetName, server.URL)
+		case "/download/asset":
+			_, _ = w.Write(asset)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	savedBaseURL := github.APIBaseURL
+	github.APIBaseURL = server.URL
+	defer func() { github.APIBaseURL = savedBaseURL }()
+
+	savedPolicyPath := policy.SystemPolicyPath
+	policy.SystemPolicyPath = filepath.Join(t.TempDir(), "policy.json")
+	defer func() { policy.SystemPolicyPath = savedPolicyPath }()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Add("gh", &registry.Executable{Source: "https://github.com/acme/tool", RepoID: 42, Version: "v1.0.0", Path: filepath.Join(t.TempDir(), "gh")})
+		return nil
+	}); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	bundlePath := filepath.Join(t.TempDir(), "tools.tar")
+	if err := Create(CreateOptions{Output: bundlePath, Targets: []string{"gh"}}); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	m, _, err := Read(bundlePath, thisPlatform(), t.TempDir())
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if len(m.Entries) != 1 || m.Entries[0].Name != "gh" {
+		t.Fatalf("expected one entry named gh, got %+v", m.Entries)
+	}
+
+	// Installing on a fresh host registers gh, not tool.
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	into := t.TempDir()
+	if err := Install(InstallOptions{Path: bundlePath, Into: into}); err != nil {
+		t.Fatalf("Install returned error: %v", err)
+	}
+	reg, err := registry.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if _, found := reg.Get("tool"); found {
+		t.Error("expected no executable named tool")
+	}
+	exec, found := reg.Get("gh")
+	if !found || exec.Path != filepath.Join(into, "gh") {
+		t.Fatalf("expected gh to be installed in %s, got %+v", into, exec)
+	}
+	if data, err := os.ReadFile(exec.Path); err != nil || string(data) != "gh binary" {
+		t.Errorf("expected the bundled binary, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,373 @@
+package bundle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/install"
+	"github.com/sfkleach/execman/pkg/policy"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/source"
+	"github.com/spf13/cobra"
+)
+
+// CreateOptions for the bundle create command.
+type CreateOptions struct {
+	Output    string
+	Targets   []string // Managed executable names or sources; empty means every managed executable.
+	Platforms []string // os/arch pairs; empty means this host's platform.
+	// AllowMoved accepts GitHub repositories that have been renamed or
+	// transferred without asking.
+	AllowMoved bool
+}
+
+// InstallOptions for the bundle install command.
+type InstallOptions struct {
+	Path string
+	Into string // Empty means each executable's current directory or the default.
+}
+
+// NewBundleCommand creates the bundle command and its subcommands.
+func NewBundleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Carry executables to hosts without internet access",
+		Long: `Package release assets into a single tar file on a host with internet access, then
+install them from that file on hosts without it.`,
+	}
+
+	var platforms []string
+	var allowMoved bool
+	createCmd := &cobra.Command{
+		Use:   "create <bundle.tar> [executable|source ...]",
+		Short: "Download release assets into a bundle",
+		Long: `Download, verify and package the release assets for the named executables, or for
+every managed executable if none are named. An argument may be the name of a managed
+executable, which bundles its installed version, or a source such as
+github.com/owner/repo[@version]. Use --platform to bundle assets for the hosts that
+will install them.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Create(CreateOptions{
+				Output:     args[0],
+				Targets:    args[1:],
+				Platforms:  platforms,
+				AllowMoved: allowMoved,
+			})
+		},
+	}
+	createCmd.Flags().StringSliceVar(&platforms, "platform", nil, "Target platform as os/arch; may be repeated (default this host's platform)")
+	createCmd.Flags().BoolVar(&allowMoved, "allow-moved", false, "Accept upstream repositories that were renamed or transferred")
+	cmd.AddCommand(createCmd)
+
+	var into string
+	installCmd := &cobra.Command{
+		Use:   "install <bundle.tar>",
+		Short: "Install the executables in a bundle",
+		Long: `Install and register the executables in a bundle that have an asset for this
+platform, without any network access. Each asset and binary must match the checksums
+recorded when the bundle was created. Executables are recorded with their original
+GitHub source, so they can be updated normally once the host is online.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Install(InstallOptions{
+				Path: args[0],
+				Into: into,
+			})
+		},
+	}
+	installCmd.Flags().StringVarP(&into, "into", "d", "", "Install newly bundled executables to this directory")
+	cmd.AddCommand(installCmd)
+
+	return cmd
+}
+
+// Create executes the bundle create command.
+func Create(opts CreateOptions) error {
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	platforms := opts.Platforms
+	if len(platforms) == 0 {
+		platforms = []string{runtime.GOOS + "/" + runtime.GOARCH}
+	}
+	for _, platform := range platforms {
+		if _, _, err := ParsePlatform(platform); err != nil {
+			return err
+		}
+	}
+
+	targets := opts.Targets
+	if len(targets) == 0 {
+		targets = reg.List()
+		if len(targets) == 0 {
+			return fmt.Errorf("no managed executables to bundle")
+		}
+	}
+
+	tempDir, err := os.MkdirTemp("", "execman-bundle-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	m := &Manifest{
+		Version:   CurrentVersion,
+		CreatedAt: time.Now().UTC(),
+	}
+	files := make(map[string]string)
+	for _, target := range targets {
+		src := target
+		name := ""
+		if exec, found := reg.Get(target); found {
+			// Keep the registry's name, which need not be the repository's.
+			src, name = exec.Source, target
+			if exec.Version != registry.UnknownVersion && !source.HasProvider(src) {
+				src += "@" + exec.Version
+			}
+		}
+		if err := addSource(m, files, src, name, platforms, cfg, tempDir, opts.AllowMoved); err != nil {
+			return fmt.Errorf("failed to bundle %s: %w", target, err)
+		}
+	}
+
+	if len(m.Entries) == 0 {
+		return fmt.Errorf("no assets found for %s", strings.Join(platforms, ", "))
+	}
+	if err := Write(opts.Output, m, files); err != nil {
+		return err
+	}
+	fmt.Printf("\nBundled %d asset(s) in %s\n", len(m.Entries), opts.Output)
+	return nil
+}
+
+// addSource downloads and verifies one release's assets for each platform
+// and adds them to the manifest under name, or the repository's name if
+// empty. A platform the release has no asset for is reported and skipped.
+func addSource(m *Manifest, files map[string]string, src, name string, platforms []string, cfg *config.Config, tempDir string, allowMoved bool) error {
+	if source.HasProvider(src) {
+		return fmt.Errorf("only GitHub sources can be bundled")
+	}
+	owner, repo, version, err := github.ParseSource(src)
+	if err != nil {
+		return err
+	}
+	if err := policy.Enforce(github.ToURL(owner, repo)); err != nil {
+		return err
+	}
+
+	repository, err := github.GetRepository(owner, repo)
+	if err != nil {
+		return err
+	}
+	if repository.Changed(owner, repo, 0) {
+		confirmed, err := install.ConfirmMoved(owner, repo, 0, repository, "bundling", false, allowMoved)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return fmt.Errorf("%s/%s has moved to %s and was not confirmed", owner, repo, repository.FullName)
+		}
+		owner, repo = repository.Owner(), repository.Name()
+		if err := policy.Enforce(github.ToURL(owner, repo)); err != nil {
+			return err
+		}
+	}
+	if name == "" {
+		name = repo
+	}
+
+	var release *github.Release
+	if version != "" {
+		fmt.Printf("\nFetching release %s from %s/%s...\n", version, owner, repo)
+		release, err = github.GetRelease(owner, repo, version)
+	} else {
+		fmt.Printf("\nFetching latest release from %s/%s...\n", owner, repo)
+		release, err = github.GetLatestRelease(owner, repo, cfg.IncludePrereleases)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, platform := range platforms {
+		goos, goarch, _ := ParsePlatform(platform)
+		asset, err := github.FindAsset(release.Assets, goos, goarch)
+		if errors.Is(err, github.ErrNoMatchingAsset) {
+			fmt.Printf("Warning: %s %s has no asset for %s\n", name, release.TagName, platform)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		assetDir := filepath.Join(tempDir, fmt.Sprintf("%d", len(files)))
+		if err := os.Mkdir(assetDir, 0700); err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		archivePath := filepath.Join(assetDir, asset.Name)
+		fmt.Printf("Downloading %s...\n", asset.Name)
+		if err := github.DownloadAsset(asset, archivePath); err != nil {
+			return err
+		}
+		provenance, err := install.VerifyDownload(release, asset, archivePath, assetDir)
+		if err != nil {
+			return err
+		}
+
+		// Record the binary's checksum too, so the installing host can
+		// check what it extracts as well as what it was given.
+		stagedDir := filepath.Join(assetDir, "staged")
+		if err := os.Mkdir(stagedDir, 0700); err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		binaryPath := filepath.Join(stagedDir, name)
+		if err := archive.ExtractBinaryWithLimits(archivePath, binaryPath, cfg.ExtractLimits()); err != nil {
+			return fmt.Errorf("failed to extract binary: %w", err)
+		}
+		checksum, err := archive.CalculateChecksum(binaryPath)
+		if err != nil {
+			return fmt.Errorf("failed to calculate checksum: %w", err)
+		}
+
+		entry := &Entry{
+			Name:            name,
+			Source:          github.ToURL(owner, repo),
+			RepoID:          repository.ID,
+			Version:         release.TagName,
+			Platform:        platform,
+			AssetName:       asset.Name,
+			AssetURL:        asset.BrowserDownloadURL,
+			ArchiveChecksum: provenance.ArchiveChecksum,
+			ChecksumSource:  provenance.ChecksumSource,
+			Checksum:        checksum,
+			DownloadedAt:    provenance.DownloadedAt,
+			File:            FileName(name, platform, asset.Name),
+		}
+		if _, dup := files[entry.File]; dup {
+			fmt.Printf("Skipping duplicate %s for %s\n", entry.Name, platform)
+			continue
+		}
+		m.Entries = append(m.Entries, entry)
+		files[entry.File] = archivePath
+	}
+	return nil
+}
+
+// Install executes the bundle install command.
+func Install(opts InstallOptions) error {
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "execman-bundle-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	m, extracted, err := Read(opts.Path, platform, tempDir)
+	if err != nil {
+		return err
+	}
+
+	installed, unchanged, failed := 0, 0, 0
+	for _, entry := range m.Entries {
+		if entry.Platform != platform {
+			continue
+		}
+		fmt.Printf("\n%s %s\n", entry.Name, entry.Version)
+
+		done, err := installOne(entry, extracted[entry.File], reg, cfg, opts)
+		switch {
+		case err != nil:
+			fmt.Printf("Failed to install %s: %v\n", entry.Name, err)
+			failed++
+		case done:
+			installed++
+		default:
+			unchanged++
+		}
+	}
+
+	missing := m.Missing(platform)
+	fmt.Printf("\n%d installed, %d already installed, %d failed.\n", installed, unchanged, failed)
+	if len(missing) > 0 {
+		fmt.Printf("No asset in the bundle for %s: %s\n", platform, strings.Join(missing, ", "))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d executable(s) could not be installed from the bundle", failed)
+	}
+	return nil
+}
+
+// installOne installs one bundled asset unless the same binary is already
+// installed, reporting whether anything was installed.
+func installOne(entry *Entry, archivePath string, reg *registry.Registry, cfg *config.Config, opts InstallOptions) (bool, error) {
+	owner, repo, _, err := github.ParseSource(entry.Source)
+	if err != nil {
+		return false, err
+	}
+
+	into := opts.Into
+	layout := ""
+	if existing, found := reg.Get(entry.Name); found {
+		existingOwner, existingRepo, _, err := github.ParseSource(existing.Source)
+		if err != nil || !strings.EqualFold(existingOwner+"/"+existingRepo, owner+"/"+repo) {
+			return false, fmt.Errorf("already managed from %s", existing.Source)
+		}
+		if existing.Version == entry.Version && existing.Checksum == entry.Checksum &&
+			archive.VerifyChecksum(existing.Path, entry.Checksum) == nil {
+			fmt.Println("Already installed.")
+			return false, nil
+		}
+		into = filepath.Dir(existing.Path)
+		layout = existing.Layout
+		if layout == "" {
+			layout = registry.LayoutCopy
+		}
+	}
+	if into == "" {
+		into = cfg.DefaultInstallDir
+	}
+
+	err = install.Run(install.Options{
+		Source:                  github.ToURL(owner, repo) + "@" + entry.Version,
+		Name:                    entry.Name,
+		Into:                    into,
+		Yes:                     true,
+		Layout:                  layout,
+		AssetName:               entry.AssetName,
+		ExpectedArchiveChecksum: entry.ArchiveChecksum,
+		ExpectedChecksum:        entry.Checksum,
+		ArchivePath:             archivePath,
+		Origin: &install.Origin{
+			RepoID:         entry.RepoID,
+			AssetURL:       entry.AssetURL,
+			ChecksumSource: entry.ChecksumSource,
+			DownloadedAt:   entry.DownloadedAt,
+		},
+	})
+	return err == nil, err
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/sfkleach/execman/pkg/config"
 	"github.com/sfkleach/execman/pkg/github"
 	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/source"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +21,7 @@ type CheckOutput struct {
 	UpdatesAvailable int                `json:"updates_available"`
 	Missing          int                `json:"missing"`
 	Modified         int                `json:"modified"`
+	Moved            int                `json:"moved"`
 }
 
 // ExecutableStatus represents the update status of an executable.
@@ -28,7 +30,8 @@ type ExecutableStatus struct {
 	CurrentVersion  string `json:"current_version"`
 	LatestVersion   string `json:"latest_version,omitempty"`
 	UpdateAvailable bool   `json:"update_available"`
-	Status          string `json:"status"` // "ok", "missing", "modified"
+	Status          string `json:"status"` // "ok", "missing", "modified", "moved"
+	MovedTo         string `json:"moved_to,omitempty"`
 }
 
 // NewCheckCommand creates the check command.
@@ -114,6 +117,7 @@ func runCheck(name string, jsonOutput, includePrereleases, noSkip, verify bool)
 	upToDateCount := 0
 	missingCount := 0
 	modifiedCount := 0
+	movedCount := 0
 
 	for _, n := range names {
 		exec, ok := reg.Get(n)
@@ -154,8 +158,8 @@ func runCheck(name string, jsonOutput, includePrereleases, noSkip, verify bool)
 			continue
 		}
 
-		// Parse source to get owner/repo.
-		owner, repo, _, err := github.ParseSource(exec.Source)
+		// Sources other than GitHub repositories have a provider.
+		provider, err := source.ForExecutable(exec)
 		if err != nil {
 			if !jsonOutput {
 				fmt.Printf("  %-15s error: %v\n", n, err)
@@ -163,16 +167,60 @@ func runCheck(name string, jsonOutput, includePrereleases, noSkip, verify bool)
 			continue
 		}
 
-		// Fetch latest release.
-		release, err := github.GetLatestRelease(owner, repo, includePrereleases)
-		if err != nil {
-			if !jsonOutput {
-				fmt.Printf("  %-15s error: %v\n", n, err)
+		var latestVersion string
+		if provider != nil {
+			latestVersion, err = provider.Latest(includePrereleases)
+			if err != nil {
+				if !jsonOutput {
+					fmt.Printf("  %-15s error: %v\n", n, err)
+				}
+				continue
 			}
-			continue
+		} else {
+			// Parse source to get owner/repo.
+			owner, repo, _, err := github.ParseSource(exec.Source)
+			if err != nil {
+				if !jsonOutput {
+					fmt.Printf("  %-15s error: %v\n", n, err)
+				}
+				continue
+			}
+
+			// Flag repositories that have been renamed or transferred since
+			// installation rather than silently checking the new location.
+			repository, err := github.GetRepository(owner, repo)
+			if err != nil {
+				if !jsonOutput {
+					fmt.Printf("  %-15s error: %v\n", n, err)
+				}
+				continue
+			}
+			if repository.Changed(owner, repo, exec.RepoID) {
+				movedCount++
+				statuses = append(statuses, ExecutableStatus{
+					Name:           n,
+					CurrentVersion: exec.Version,
+					Status:         "moved",
+					MovedTo:        github.ToURL(repository.Owner(), repository.Name()),
+				})
+				if !jsonOutput {
+					fmt.Printf("  %-15s %-9s          MOVED to %s\n", n, exec.Version, repository.FullName)
+				}
+				continue
+			}
+
+			// Fetch latest release.
+			release, err := github.GetLatestRelease(owner, repo, includePrereleases)
+			if err != nil {
+				if !jsonOutput {
+					fmt.Printf("  %-15s error: %v\n", n, err)
+				}
+				continue
+			}
+
+			latestVersion = release.TagName
 		}
 
-		latestVersion := release.TagName
 		updateAvailable := exec.Version != latestVersion
 
 		if updateAvailable {
@@ -209,6 +257,7 @@ func runCheck(name string, jsonOutput, includePrereleases, noSkip, verify bool)
 			UpdatesAvailable: updatesAvailable,
 			Missing:          missingCount,
 			Modified:         modifiedCount,
+			Moved:            movedCount,
 		}
 		encoder := json.NewEncoder(os.Stdout)
 		encoder.SetIndent("", "  ")
@@ -226,6 +275,9 @@ func runCheck(name string, jsonOutput, includePrereleases, noSkip, verify bool)
 	if modifiedCount > 0 {
 		parts = append(parts, fmt.Sprintf("%d modified", modifiedCount))
 	}
+	if movedCount > 0 {
+		parts = append(parts, fmt.Sprintf("%d moved", movedCount))
+	}
 	parts = append(parts, fmt.Sprintf("%d up to date", upToDateCount))
 	if updatesAvailable == 1 {
 		parts = append(parts, "1 update available")
@@ -237,6 +289,8 @@ func runCheck(name string, jsonOutput, includePrereleases, noSkip, verify bool)
 
 	if missingCount > 0 || modifiedCount > 0 {
 		fmt.Println("Run 'execman update <name>' to reinstall missing or modified executables.")
+	} else if movedCount > 0 {
+		fmt.Println("Run 'execman update <name>' to review and confirm the new upstream location.")
 	} else if updatesAvailable > 0 {
 		fmt.Println("Run 'execman update' to install updates.")
 	}
@@ -5,13 +5,80 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/atomicfile"
+	"github.com/sfkleach/execman/pkg/lock"
+	"github.com/sfkleach/execman/pkg/registry"
 )
 
 // Config represents the execman configuration.
 type Config struct {
 	DefaultInstallDir  string `json:"default_install_dir,omitempty"`
 	IncludePrereleases bool   `json:"include_prereleases"`
-	path               string // internal, not serialized
+	// Extraction limits in bytes. Zero means the archive package default.
+	MaxExtractedFileSize int64 `json:"max_extracted_file_size,omitempty"`
+	MaxArchiveExpansion  int64 `json:"max_archive_expansion,omitempty"`
+	// PreActivationHook is a shell command run against each new binary
+	// before it is put in place; a non-zero exit status aborts the install
+	// or update. See the hook package for how it is run.
+	PreActivationHook string `json:"pre_activation_hook,omitempty"`
+	// StoreDir holds execman-managed copies of executables, such as previous
+	// versions kept for rollback. Defaults to "store" beside the config file.
+	StoreDir string `json:"store_dir,omitempty"`
+	// KeepVersions is how many previous versions of each executable to keep
+	// for rollback. Zero disables version history.
+	KeepVersions int `json:"keep_versions"`
+	// Layout is how new executables are installed: "copy" puts the binary
+	// in the install directory, "versioned" keeps it in the store and links
+	// to it. Defaults to "copy".
+	Layout string `json:"layout,omitempty"`
+	// SymlinkPolicy is how update and remove handle an executable that is a
+	// symlink when neither --symlink nor a remembered choice says otherwise:
+	// "target", "link" or "skip". Empty means ask.
+	SymlinkPolicy string `json:"symlink_policy,omitempty"`
+	// Mirrors maps upstream hosts, or "*" for any host, to URL templates of
+	// a download mirror to fetch from instead, such as
+	// "http://mirror.local:8080/{host}/{path}". Sources are still recorded
+	// with their upstream URLs.
+	Mirrors map[string]string `json:"mirrors,omitempty"`
+	// ConnectTimeout and Timeout bound connecting to a server and a whole
+	// request, including reading the response, as Go durations such as
+	// "30s". Empty means the httpclient package default; a Timeout of "0"
+	// means none.
+	ConnectTimeout string `json:"connect_timeout,omitempty"`
+	Timeout        string `json:"timeout,omitempty"`
+	// CABundle is a PEM file of certificate authorities trusted in addition
+	// to the system's, such as a corporate proxy's.
+	CABundle string `json:"ca_bundle,omitempty"`
+	// ClientCert and ClientKey are PEM files of a certificate presented to
+	// servers that require one.
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	path       string // internal, not serialized
+}
+
+// DefaultKeepVersions is the number of previous versions kept when the
+// config does not say otherwise.
+const DefaultKeepVersions = 3
+
+// ExtractLimits returns the configured archive extraction limits.
+func (c *Config) ExtractLimits() archive.Limits {
+	return archive.Limits{
+		MaxFileSize:  c.MaxExtractedFileSize,
+		MaxTotalSize: c.MaxArchiveExpansion,
+	}
+}
+
+// ValidateLayout returns an error unless layout is a known install layout.
+func ValidateLayout(layout string) error {
+	switch layout {
+	case registry.LayoutCopy, registry.LayoutVersioned:
+		return nil
+	default:
+		return fmt.Errorf("unknown layout %q (expected %q or %q)", layout, registry.LayoutCopy, registry.LayoutVersioned)
+	}
 }
 
 // DefaultConfigPath returns the default config file path.
@@ -43,6 +110,9 @@ func LoadFrom(path string) (*Config, error) {
 		return &Config{
 			DefaultInstallDir:  filepath.Join(homeDir, ".local", "bin"),
 			IncludePrereleases: false,
+			StoreDir:           filepath.Join(filepath.Dir(path), "store"),
+			KeepVersions:       DefaultKeepVersions,
+			Layout:             registry.LayoutCopy,
 			path:               path,
 		}, nil
 	}
@@ -53,7 +123,9 @@ func LoadFrom(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
-	var cfg Config
+	// Defaults for fields whose zero value is meaningful are set before
+	// parsing, so that they only apply when the key is absent.
+	cfg := Config{KeepVersions: DefaultKeepVersions}
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
@@ -68,10 +140,50 @@ func LoadFrom(path string) (*Config, error) {
 		}
 		cfg.DefaultInstallDir = filepath.Join(homeDir, ".local", "bin")
 	}
+	if cfg.StoreDir == "" {
+		cfg.StoreDir = filepath.Join(filepath.Dir(path), "store")
+	}
+	if cfg.Layout == "" {
+		cfg.Layout = registry.LayoutCopy
+	}
 
 	return &cfg, nil
 }
 
+// LockTimeout is how long a config update waits for another execman process
+// to finish before giving up.
+var LockTimeout = 30 * time.Second
+
+// Update loads the config from the default location while holding the
+// execman lock, applies fn to it and saves the result. If fn returns an
+// error, nothing is saved.
+func Update(fn func(*Config) error) error {
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return err
+	}
+	return UpdateAt(path, fn)
+}
+
+// UpdateAt is like Update but for the config at a specific path. The lock
+// file is shared with a registry in the same directory.
+func UpdateAt(path string, fn func(*Config) error) error {
+	l, err := lock.Acquire(filepath.Join(filepath.Dir(path), "execman.lock"), LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(cfg); err != nil {
+		return err
+	}
+	return cfg.Save()
+}
+
 // Save saves the config to disk.
 func (c *Config) Save() error {
 	// Ensure directory exists.
@@ -86,7 +198,7 @@ func (c *Config) Save() error {
 	}
 
 	// Use 0600 permissions for config file (user read/write only).
-	if err := os.WriteFile(c.path, data, 0600); err != nil {
+	if err := atomicfile.WriteFile(c.path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
@@ -0,0 +1,199 @@
+// Package export moves a set of managed executables between machines. The
+// export is portable: paths are relative to the install directory and no
+// platform or asset is recorded, so the importing machine picks its own.
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/atomicfile"
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+// CurrentVersion is the export format version written by this build.
+const CurrentVersion = 1
+
+// Export is the portable form of a registry.
+type Export struct {
+	Version     int               `json:"export_version"`
+	ExportedAt  time.Time         `json:"exported_at"`
+	Executables map[string]*Entry `json:"executables"`
+}
+
+// Entry is the portable form of one registry entry.
+type Entry struct {
+	Source  string `json:"source"`
+	Version string `json:"version"`
+	// Path is relative to the install directory when the executable lives
+	// there, starts with ~/ when it lives elsewhere under the home
+	// directory, and is absolute otherwise.
+	Path   string `json:"path"`
+	Layout string `json:"layout,omitempty"`
+	// URLSource is set for executables whose Source is a download URL
+	// template.
+	URLSource *registry.URLSource `json:"url_source,omitempty"`
+	// BuildFromSource is set for Go tools that were built with go install
+	// because their release had no asset for the platform.
+	BuildFromSource bool `json:"build_from_source,omitempty"`
+}
+
+// FromRegistry builds the portable form of reg, relative to installDir and
+// home.
+func FromRegistry(reg *registry.Registry, installDir, home string) *Export {
+	exp := &Export{
+		Version:     CurrentVersion,
+		ExportedAt:  time.Now().UTC(),
+		Executables: make(map[string]*Entry),
+	}
+	for _, name := range reg.List() {
+		exec, _ := reg.Get(name)
+		exp.Executables[name] = &Entry{
+			Source:          exec.Source,
+			Version:         exec.Version,
+			Path:            portablePath(exec.Path, installDir, home),
+			Layout:          exec.Layout,
+			URLSource:       exec.URLSource,
+			BuildFromSource: exec.BuiltFrom != "",
+		}
+	}
+	return exp
+}
+
+// portablePath expresses path relative to installDir or home where it can.
+func portablePath(path, installDir, home string) string {
+	if rel, ok := within(path, installDir); ok {
+		return filepath.ToSlash(rel)
+	}
+	if rel, ok := within(path, home); ok {
+		return "~/" + filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// within returns path relative to dir if it lies inside dir.
+func within(path, dir string) (string, bool) {
+	if dir == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// LocalPath resolves an exported path on this machine.
+func (e *Entry) LocalPath(installDir, home string) string {
+	path := filepath.FromSlash(e.Path)
+	switch {
+	case strings.HasPrefix(e.Path, "~/"):
+		return filepath.Join(home, filepath.FromSlash(e.Path[2:]))
+	case filepath.IsAbs(path):
+		return path
+	default:
+		return filepath.Join(installDir, path)
+	}
+}
+
+// Names returns the exported executable names in order.
+func (exp *Export) Names() []string {
+	names := make([]string, 0, len(exp.Executables))
+	for name := range exp.Executables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load reads an export.
+func Load(path string) (*Export, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		// #nosec G304 -- Reading the export named by the user
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export: %w", err)
+	}
+
+	var exp Export
+	if err := json.Unmarshal(data, &exp); err != nil {
+		return nil, fmt.Errorf("failed to parse export: %w", err)
+	}
+	if exp.Version > CurrentVersion {
+		return nil, fmt.Errorf("export version %d is newer than this execman supports (%d); upgrade execman", exp.Version, CurrentVersion)
+	}
+	for name, entry := range exp.Executables {
+		if entry == nil || entry.Source == "" {
+			return nil, fmt.Errorf("export entry %s has no source", name)
+		}
+	}
+	return &exp, nil
+}
+
+// NewExportCommand creates the export command.
+func NewExportCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the managed executables for another machine",
+		Long: `Write the managed executables in a portable form, to stdout or a file. Paths are
+recorded relative to the install directory and no platform is recorded, so
+'execman import' on another machine installs the assets for that machine.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to a file instead of stdout")
+
+	return cmd
+}
+
+func runExport(output string) error {
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get user home directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(FromRegistry(reg, cfg.DefaultInstallDir, home), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal export: %w", err)
+	}
+	data = append(data, '\n')
+
+	if output == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	// #nosec G306 -- The export holds no secrets and is meant to be copied
+	if err := atomicfile.WriteFile(output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d executable(s) to %s\n", len(reg.List()), output)
+	return nil
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+func TestPortablePathRoundTrip(t *testing.T) {
+	installDir := filepath.Join("/", "home", "alice", "bin")
+	home := filepath.Join("/", "home", "alice")
+
+	tests := []struct {
+		name     string
+		path     string
+		portable string
+	}{
+		{name: "install dir", path: filepath.Join(installDir, "tool"), portable: "tool"},
+		{name: "install subdir", path: filepath.Join(installDir, "extra", "tool"), portable: "extra/tool"},
+		{name: "home", path: filepath.Join(home, ".local", "bin", "tool"), portable: "~/.local/bin/tool"},
+		{name: "elsewhere", path: filepath.Join("/", "opt", "tool"), portable: filepath.Join("/", "opt", "tool")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := portablePath(tt.path, installDir, home)
+			if got != tt.portable {
+				t.Fatalf("portablePath(%q) = %q, want %q", tt.path, got, tt.portable)
+			}
+
+			// On the new machine the install dir and home differ.
+			newDir := filepath.Join("/", "srv", "bin")
+			newHome := filepath.Join("/", "home", "bob")
+			entry := &Entry{Path: got}
+			want := tt.path
+			if rel, ok := within(tt.path, installDir); ok {
+				want = filepath.Join(newDir, rel)
+			} else if rel, ok := within(tt.path, home); ok {
+				want = filepath.Join(newHome, rel)
+			}
+			if local := entry.LocalPath(newDir, newHome); local != want {
+				t.Errorf("LocalPath(%q) = %q, want %q", got, local, want)
+			}
+		})
+	}
+}
+
+func TestFromRegistryOmitsPlatform(t *testing.T) {
+	reg := &registry.Registry{Executables: map[string]*registry.Executable{
+		"pathman": {
+			Source:    "https://github.com/sfkleach/pathman",
+			Version:   "v0.3.0",
+			Path:      "/home/alice/bin/pathman",
+			Platform:  "linux/amd64",
+			AssetName: "pathman_Linux_x86_64.tar.gz",
+			Layout:    registry.LayoutVersioned,
+		},
+	}}
+
+	data, err := json.Marshal(FromRegistry(reg, "/home/alice/bin", "/home/alice"))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+
+	exp, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	entry := exp.Executables["pathman"]
+	if entry == nil || entry.Path != "pathman" || entry.Version != "v0.3.0" || entry.Layout != registry.LayoutVersioned {
+		t.Fatalf("unexpected exported entry %+v", entry)
+	}
+
+	var raw struct {
+		Executables map[string]map[string]any `json:"executables"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	for _, field := range []string{"platform", "asset_name", "checksum"} {
+		if _, ok := raw.Executables["pathman"][field]; ok {
+			t.Errorf("export should not record %s", field)
+		}
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, []byte(`{"export_version": 99, "executables": {}}`), 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected an error for a newer export version")
+	}
+}
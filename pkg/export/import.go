@@ -0,0 +1,138 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/install"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/source"
+	"github.com/spf13/cobra"
+)
+
+// ImportOptions for the import command.
+type ImportOptions struct {
+	Path   string // "-" means stdin.
+	Into   string // Install directory for relative paths; empty means the configured default.
+	Latest bool   // Install the latest release rather than the exported version.
+}
+
+// NewImportCommand creates the import command.
+func NewImportCommand() *cobra.Command {
+	var into string
+	var latest bool
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Install the executables from an export",
+		Long: `Install every executable listed in a file written by 'execman export' (or '-' for
+stdin), choosing the release assets for this machine's platform. Executables
+already installed at the exported version are left alone. Tools whose release has
+no asset for this platform are reported at the end.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Import(ImportOptions{
+				Path:   args[0],
+				Into:   into,
+				Latest: latest,
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&into, "into", "d", "", "Install directory for executables exported from the install directory")
+	cmd.Flags().BoolVar(&latest, "latest", false, "Install the latest release instead of the exported version")
+
+	return cmd
+}
+
+// Import executes the import command.
+func Import(opts ImportOptions) error {
+	exp, err := Load(opts.Path)
+	if err != nil {
+		return err
+	}
+
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	installDir := opts.Into
+	if installDir == "" {
+		installDir = cfg.DefaultInstallDir
+	}
+
+	installed, present := 0, 0
+	var noAsset, failed []string
+	for _, name := range exp.Names() {
+		entry := exp.Executables[name]
+		fmt.Printf("\n%s\n", name)
+
+		if existing, found := reg.Get(name); found && sameSource(existing.Source, entry.Source) &&
+			(opts.Latest || existing.Version == entry.Version) {
+			fmt.Printf("Already installed (%s).\n", existing.Version)
+			present++
+			continue
+		}
+
+		version := ""
+		if !opts.Latest && entry.Version != registry.UnknownVersion {
+			version = entry.Version
+		}
+		err := install.Run(install.Options{
+			Source:    entry.Source,
+			Version:   version,
+			Name:      name,
+			URLSource: entry.URLSource,
+			Into:      filepath.Dir(entry.LocalPath(installDir, home)),
+			Yes:       true,
+			Layout:    entry.Layout,
+
+			BuildFromSource: entry.BuildFromSource,
+		})
+		switch {
+		case errors.Is(err, github.ErrNoMatchingAsset):
+			noAsset = append(noAsset, name)
+		case err != nil:
+			fmt.Printf("Failed to import %s: %v\n", name, err)
+			failed = append(failed, name)
+		default:
+			installed++
+		}
+	}
+
+	fmt.Printf("\n%d installed, %d already installed, %d without an asset for this platform, %d failed.\n",
+		installed, present, len(noAsset), len(failed))
+	if len(noAsset) > 0 {
+		fmt.Printf("No release asset for this platform: %s\n", strings.Join(noAsset, ", "))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to import %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// sameSource reports whether two source strings name the same GitHub
+// repository, download URL template or registry repository.
+func sameSource(a, b string) bool {
+	if source.HasProvider(a) || source.HasProvider(b) {
+		return a == b
+	}
+	aOwner, aRepo, _, aErr := github.ParseSource(a)
+	bOwner, bRepo, _, bErr := github.ParseSource(b)
+	return aErr == nil && bErr == nil && strings.EqualFold(aOwner+"/"+aRepo, bOwner+"/"+bRepo)
+}
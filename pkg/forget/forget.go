@@ -4,9 +4,13 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/sfkleach/execman/pkg/atomicfile"
+	"github.com/sfkleach/execman/pkg/config"
 	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/store"
 	"github.com/spf13/cobra"
 )
 
@@ -73,11 +77,54 @@ func Forget(opts Options) error {
 		}
 	}
 
+	// A versioned install's symlink points into execman's store, so the
+	// binary itself is put in its place before the store is cleaned up.
+	var replacement *atomicfile.Replacement
+	if exec.Versioned() {
+		if target, err := filepath.EvalSymlinks(exec.Path); err == nil {
+			replacement, err = atomicfile.ReplaceExecutable(target, exec.Path)
+			if err != nil {
+				return fmt.Errorf("failed to replace symlink with a copy of %s: %w", target, err)
+			}
+		}
+	}
+
 	// Remove from registry.
-	reg.Remove(opts.Name)
-	if err := reg.Save(); err != nil {
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Remove(opts.Name)
+		return nil
+	}); err != nil {
+		if replacement != nil {
+			if restoreErr := replacement.Restore(); restoreErr != nil {
+				return fmt.Errorf("failed to update registry: %w (and failed to restore symlink: %v)", err, restoreErr)
+			}
+		}
 		return fmt.Errorf("failed to update registry: %w", err)
 	}
+	if replacement != nil {
+		if err := replacement.Commit(); err != nil {
+			fmt.Printf("Warning: failed to remove previous symlink: %v\n", err)
+		}
+	}
+
+	// Previous versions kept for rollback are no longer reachable, and in
+	// the versioned layout the current one has been copied out.
+	stored := exec.History
+	if replacement != nil {
+		stored = append([]*registry.Executable{exec}, stored...)
+	}
+	if len(stored) > 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Printf("Warning: previous versions not deleted: failed to load config: %v\n", err)
+		} else {
+			for _, old := range stored {
+				if err := store.Delete(cfg.StoreDir, opts.Name, old.Version); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+			}
+		}
+	}
 
 	// Report success.
 	fmt.Printf("\n%s forgotten (file kept at %s)\n", opts.Name, exec.Path)
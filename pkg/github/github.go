@@ -2,14 +2,31 @@ package github
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"regexp"
 	"strings"
+
+	"github.com/sfkleach/execman/pkg/httpclient"
 )
 
+// APIBaseURL is the base URL of the GitHub REST API. It is a variable so that
+// tests can substitute a local server.
+var APIBaseURL = "https://api.github.com"
+
+// Repository represents a GitHub repository.
+type Repository struct {
+	ID       int64  `json:"id"`
+	FullName string `json:"full_name"`
+	// Redirected is set when GitHub answered the request by redirecting to a
+	// different location, which happens when a repository is renamed or
+	// transferred. It is not part of the API response.
+	Redirected bool `json:"-"`
+}
+
 // Release represents a GitHub release.
 type Release struct {
 	TagName    string  `json:"tag_name"`
@@ -61,12 +78,70 @@ func ToURL(owner, repo string) string {
 	return fmt.Sprintf("https://github.com/%s/%s", owner, repo)
 }
 
-// GetLatestRelease fetches the latest release from GitHub.
-func GetLatestRelease(owner, repo string, includePrereleases bool) (*Release, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases", owner, repo)
+// GetRepository fetches repository metadata from GitHub, noting whether the
+// request was redirected.
+func GetRepository(owner, repo string) (*Repository, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s", APIBaseURL, owner, repo)
+
+	// #nosec G107 -- URL is constructed from validated GitHub repo components
+	resp, err := httpclient.Client().Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repository: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, fmt.Errorf("repository %s/%s not found", owner, repo)
+		case http.StatusForbidden:
+			return nil, fmt.Errorf("access forbidden (rate limit exceeded or private repository): %s/%s", owner, repo)
+		case http.StatusUnauthorized:
+			return nil, fmt.Errorf("authentication required to access %s/%s", owner, repo)
+		default:
+			body, _ := io.ReadAll(resp.Body)
+			return nil, fmt.Errorf("GitHub API error (status %d): %s", resp.StatusCode, string(body))
+		}
+	}
+
+	var repository Repository
+	if err := json.NewDecoder(resp.Body).Decode(&repository); err != nil {
+		return nil, fmt.Errorf("failed to parse repository: %w", err)
+	}
+	repository.Redirected = resp.Request.URL.String() != url
+
+	return &repository, nil
+}
+
+// Owner returns the owner part of the repository's full name.
+func (r *Repository) Owner() string {
+	owner, _, _ := strings.Cut(r.FullName, "/")
+	return owner
+}
+
+// Name returns the repository part of the repository's full name.
+func (r *Repository) Name() string {
+	_, name, _ := strings.Cut(r.FullName, "/")
+	return name
+}
+
+// Changed reports whether the repository is no longer the one recorded as
+// owner/repo with the given ID. A recordedID of zero means no ID was recorded,
+// so only the name is compared.
+func (r *Repository) Changed(owner, repo string, recordedID int64) bool {
+	if r.Redirected || !strings.EqualFold(r.FullName, owner+"/"+repo) {
+		return true
+	}
+	return recordedID != 0 && r.ID != recordedID
+}
+
+// ListReleases fetches the most recent releases of a repository, newest
+// first, including prereleases.
+func ListReleases(owner, repo string) ([]Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases", APIBaseURL, owner, repo)
 
 	// #nosec G107 -- URL is constructed from validated GitHub repo components
-	resp, err := http.Get(url)
+	resp, err := httpclient.Client().Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch releases: %w", err)
 	}
@@ -91,6 +166,16 @@ func GetLatestRelease(owner, repo string, includePrereleases bool) (*Release, er
 		return nil, fmt.Errorf("failed to parse releases: %w", err)
 	}
 
+	return releases, nil
+}
+
+// GetLatestRelease fetches the latest release from GitHub.
+func GetLatestRelease(owner, repo string, includePrereleases bool) (*Release, error) {
+	releases, err := ListReleases(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(releases) == 0 {
 		return nil, fmt.Errorf("no releases found for %s/%s", owner, repo)
 	}
@@ -107,10 +192,10 @@ func GetLatestRelease(owner, repo string, includePrereleases bool) (*Release, er
 
 // GetRelease fetches a specific release by tag from GitHub.
 func GetRelease(owner, repo, tag string) (*Release, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/tags/%s", owner, repo, tag)
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", APIBaseURL, owner, repo, tag)
 
 	// #nosec G107 -- URL is constructed from validated GitHub repo components
-	resp, err := http.Get(url)
+	resp, err := httpclient.Client().Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch release: %w", err)
 	}
@@ -138,6 +223,10 @@ func GetRelease(owner, repo, tag string) (*Release, error) {
 	return &release, nil
 }
 
+// ErrNoMatchingAsset is returned by FindAsset when a release has no asset
+// for the requested platform.
+var ErrNoMatchingAsset = errors.New("no matching asset found")
+
 // FindAsset finds a matching asset for the given OS and architecture.
 func FindAsset(assets []Asset, osName, arch string) (*Asset, error) {
 	// Build architecture pattern with common aliases.
@@ -163,12 +252,12 @@ func FindAsset(assets []Asset, osName, arch string) (*Asset, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("no matching asset found for %s/%s", osName, arch)
+	return nil, fmt.Errorf("%w for %s/%s", ErrNoMatchingAsset, osName, arch)
 }
 
 // DownloadAsset downloads an asset from GitHub.
 func DownloadAsset(asset *Asset, dest string) error {
-	resp, err := http.Get(asset.BrowserDownloadURL)
+	resp, err := httpclient.Client().Get(asset.BrowserDownloadURL)
 	if err != nil {
 		return fmt.Errorf("failed to download asset: %w", err)
 	}
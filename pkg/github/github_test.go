@@ -1,6 +1,8 @@
 package github
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -224,3 +226,97 @@ func TestParseSource(t *testing.T) {
 		})
 	}
 }
+
+func TestGetRepositoryDetectsMoves(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo":
+			_, _ = w.Write([]byte(`{"id": 42, "full_name": "owner/repo"}`))
+		case "/repos/old-owner/repo":
+			// GitHub answers requests for transferred repositories with a
+			// redirect to the repository's permanent ID.
+			http.Redirect(w, r, "/repositories/42", http.StatusMovedPermanently)
+		case "/repositories/42":
+			_, _ = w.Write([]byte(`{"id": 42, "full_name": "new-owner/repo"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	savedBaseURL := APIBaseURL
+	APIBaseURL = server.URL
+	defer func() { APIBaseURL = savedBaseURL }()
+
+	tests := []struct {
+		name        string
+		owner       string
+		recordedID  int64
+		wantChanged bool
+	}{
+		{name: "Unchanged", owner: "owner", recordedID: 42},
+		{name: "No recorded ID", owner: "owner"},
+		{name: "Different ID", owner: "owner", recordedID: 7, wantChanged: true},
+		{name: "Transferred", owner: "old-owner", recordedID: 42, wantChanged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository, err := GetRepository(tt.owner, "repo")
+			if err != nil {
+				t.Fatalf("GetRepository() unexpected error: %v", err)
+			}
+			if got := repository.Changed(tt.owner, "repo", tt.recordedID); got != tt.wantChanged {
+				t.Errorf("Changed() = %v, want %v", got, tt.wantChanged)
+			}
+		})
+	}
+
+	if _, err := GetRepository("missing", "repo"); err == nil {
+		t.Error("GetRepository() expected error for missing repository, got nil")
+	}
+}
+
+func TestGetLatestReleaseSkipsPrereleases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`[
+			{"tag_name": "v2.0.0-rc1", "prerelease": true},
+			{"tag_name": "v1.1.0"},
+			{"tag_name": "v1.0.0"}
+		]`))
+	}))
+	defer server.Close()
+
+	savedBaseURL := APIBaseURL
+	APIBaseURL = server.URL
+	defer func() { APIBaseURL = savedBaseURL }()
+
+	releases, err := ListReleases("owner", "repo")
+	if err != nil {
+		t.Fatalf("ListReleases() unexpected error: %v", err)
+	}
+	if len(releases) != 3 {
+		t.Errorf("ListReleases() returned %d releases, want 3", len(releases))
+	}
+
+	tests := []struct {
+		includePrereleases bool
+		want               string
+	}{
+		{includePrereleases: false, want: "v1.1.0"},
+		{includePrereleases: true, want: "v2.0.0-rc1"},
+	}
+	for _, tt := range tests {
+		release, err := GetLatestRelease("owner", "repo", tt.includePrereleases)
+		if err != nil {
+			t.Fatalf("GetLatestRelease() unexpected error: %v", err)
+		}
+		if release.TagName != tt.want {
+			t.Errorf("GetLatestRelease(%v) = %s, want %s", tt.includePrereleases, release.TagName, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package gobuild builds an executable from a Go module's source with the
+// local Go toolchain, for releases that publish no asset for the platform.
+package gobuild
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sfkleach/execman/pkg/semver"
+)
+
+// ErrNoToolchain is returned when no go command is found on the PATH.
+var ErrNoToolchain = errors.New("no Go toolchain found on PATH")
+
+// Result describes a binary built from source.
+type Result struct {
+	Path    string // Local path of the built binary.
+	Package string // The package that was installed, without a version.
+}
+
+// Candidates returns the packages that may hold the main package of a
+// GitHub repository at version, most likely first: recorded, if set, then
+// the module root and cmd/<repo>, allowing for the major version suffix
+// that modules at v2 and above carry.
+func Candidates(owner, repo, version, recorded string) []string {
+	module := "github.com/" + owner + "/" + repo
+	if v, err := semver.Parse(version); err == nil && v.Major >= 2 {
+		module += fmt.Sprintf("/v%d", v.Major)
+	}
+
+	var candidates []string
+	if recorded != "" {
+		candidates = append(candidates, recorded)
+	}
+	for _, pkg := range []string{module, module + "/cmd/" + repo} {
+		if pkg != recorded {
+			candidates = append(candidates, pkg)
+		}
+	}
+	return candidates
+}
+
+// Build runs go install for the first of packages that builds at version,
+// with GOBIN set to a directory inside dir. Go verifies the module source
+// against its checksum database as for any other download.
+func Build(packages []string, version, dir string) (*Result, error) {
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		return nil, ErrNoToolchain
+	}
+
+	var failures []string
+	for i, pkg := range packages {
+		binDir := filepath.Join(dir, fmt.Sprintf("gobin-%d", i))
+		if err := os.Mkdir(binDir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create build directory: %w", err)
+		}
+
+		target := pkg + "@" + version
+		fmt.Printf("Building %s with go install...\n", target)
+		// #nosec G204 -- Runs the go command with a package path derived from the source
+		cmd := exec.Command(goCmd, "install", target)
+		// Run outside any module or workspace, so that only the release's
+		// own go.mod decides its dependencies.
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOBIN="+binDir, "GOWORK=off")
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", target, lastLine(output.String(), err)))
+			continue
+		}
+
+		path, err := builtBinary(binDir)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Path: path, Package: pkg}, nil
+	}
+	return nil, fmt.Errorf("failed to build from source: %s", strings.Join(failures, "; "))
+}
+
+// builtBinary returns the single file go install left in binDir.
+func builtBinary(binDir string) (string, error) {
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read build directory: %w", err)
+	}
+	if len(entries) != 1 || !entries[0].Type().IsRegular() {
+		return "", fmt.Errorf("go install produced %d files, expected one executable", len(entries))
+	}
+	return filepath.Join(binDir, entries[0].Name()), nil
+}
+
+// lastLine returns the last non-empty line of a command's output, which is
+// where go reports why it failed, or err if there was no output.
+func lastLine(output string, err error) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if line := strings.TrimSpace(lines[len(lines)-1]); line != "" {
+		return line
+	}
+	return err.Error()
+}
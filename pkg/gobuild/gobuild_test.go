@@ -0,0 +1,142 @@
+package gobuild
+
+import (
+	"archive/zip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		recorded string
+		want     []string
+	}{
+		{
+			name:    "v1",
+			version: "v1.4.0",
+			want:    []string{"github.com/acme/tool", "github.com/acme/tool/cmd/tool"},
+		},
+		{
+			name:    "major version suffix",
+			version: "v3.0.1",
+			want:    []string{"github.com/acme/tool/v3", "github.com/acme/tool/v3/cmd/tool"},
+		},
+		{
+			name:     "recorded first",
+			version:  "v1.5.0",
+			recorded: "github.com/acme/tool/cmd/tool",
+			want:     []string{"github.com/acme/tool/cmd/tool", "github.com/acme/tool"},
+		},
+		{
+			name:     "recorded elsewhere",
+			version:  "v1.5.0",
+			recorded: "github.com/acme/tool/tools/tool",
+			want:     []string{"github.com/acme/tool/tools/tool", "github.com/acme/tool", "github.com/acme/tool/cmd/tool"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Candidates("acme", "tool", tt.version, tt.recorded)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Candidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeModule adds a module version to a file-based module proxy.
+func writeModule(t *testing.T, proxy, module, version string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(proxy, filepath.FromSlash(module), "@v")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create proxy directory: %v", err)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	write("list", version+"\n")
+	write(version+".info", `{"Version":"`+version+`","Time":"2026-01-01T00:00:00Z"}`)
+	write(version+".mod", files["go.mod"])
+
+	out, err := os.Create(filepath.Join(dir, version+".zip"))
+	if err != nil {
+		t.Fatalf("failed to create zip: %v", err)
+	}
+	zw := zip.NewWriter(out)
+	for name, content := range files {
+		w, err := zw.Create(module + "@" + version + "/" + name)
+		if err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("no Go toolchain")
+	}
+
+	// Serve modules from a local proxy so that the test needs no network.
+	proxy := t.TempDir()
+	t.Setenv("GOPROXY", "file://"+filepath.ToSlash(proxy))
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOTOOLCHAIN", "local")
+	t.Setenv("GOMODCACHE", t.TempDir())
+	t.Setenv("GOFLAGS", "-modcacherw")
+
+	writeModule(t, proxy, "github.com/acme/hello", "v1.0.0", map[string]string{
+		"go.mod":  "module github.com/acme/hello\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() { println(\"hello\") }\n",
+	})
+	writeModule(t, proxy, "github.com/acme/lib/v2", "v2.1.0", map[string]string{
+		"go.mod":          "module github.com/acme/lib/v2\n\ngo 1.21\n",
+		"lib.go":          "package lib\n\nconst Name = \"lib\"\n",
+		"cmd/lib/main.go": "package main\n\nimport \"github.com/acme/lib/v2\"\n\nfunc main() { println(lib.Name) }\n",
+	})
+
+	tests := []struct {
+		repo        string
+		version     string
+		wantPackage string
+	}{
+		{repo: "hello", version: "v1.0.0", wantPackage: "github.com/acme/hello"},
+		{repo: "lib", version: "v2.1.0", wantPackage: "github.com/acme/lib/v2/cmd/lib"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			result, err := Build(Candidates("acme", tt.repo, tt.version, ""), tt.version, t.TempDir())
+			if err != nil {
+				t.Fatalf("Build returned error: %v", err)
+			}
+			if result.Package != tt.wantPackage {
+				t.Errorf("Package = %q, want %q", result.Package, tt.wantPackage)
+			}
+			if name := strings.TrimSuffix(filepath.Base(result.Path), ".exe"); name != tt.repo {
+				t.Errorf("built %s, want %s", result.Path, tt.repo)
+			}
+		})
+	}
+
+	if _, err := Build(Candidates("acme", "missing", "v1.0.0", ""), "v1.0.0", t.TempDir()); err == nil {
+		t.Error("expected an error building a module that does not exist")
+	}
+}
@@ -0,0 +1,75 @@
+// Package hook runs the user-configured pre-activation hook against a newly
+// extracted binary before it is moved onto the PATH.
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+// Placeholder stands for the path of the binary being checked.
+const Placeholder = "{file}"
+
+// FileVariable is the environment variable that holds the path of the binary
+// being checked while the hook runs.
+const FileVariable = "EXECMAN_FILE"
+
+// ErrRejected is returned when the hook exits with a non-zero status.
+var ErrRejected = errors.New("pre-activation hook rejected the binary")
+
+// Run runs the hook command against file. The command is run by the shell,
+// sh -c or cmd /C on Windows, so that it may quote arguments and paths with
+// spaces in. The path is passed in FileVariable, and every {file} in the
+// command is replaced by a quoted reference to it; if there is none the
+// reference is appended as the last argument. The hook's output is passed
+// through to the user.
+//
+// A nil result and nil error mean no hook is configured. When the hook runs,
+// the result is returned even if it rejected the binary, so that callers can
+// report it.
+func Run(command, file string) (*registry.HookResult, error) {
+	if strings.TrimSpace(command) == "" {
+		return nil, nil
+	}
+
+	script, shown := command, command
+	if strings.Contains(command, Placeholder) {
+		script = strings.ReplaceAll(command, Placeholder, fileReference)
+		shown = strings.ReplaceAll(command, Placeholder, file)
+	} else {
+		script += " " + fileReference
+		shown += " " + file
+	}
+
+	fmt.Printf("Running pre-activation hook: %s\n", shown)
+	// #nosec G204 -- The hook command comes from the user's own configuration
+	cmd := shellCommand(script)
+	cmd.Env = append(os.Environ(), FileVariable+"="+file)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	result := &registry.HookResult{
+		Command: command,
+		RanAt:   time.Now(),
+	}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			// A hook that cannot be started must not be mistaken for a pass.
+			return nil, fmt.Errorf("failed to run pre-activation hook: %w", err)
+		}
+		if exitErr.ExitCode() == notFoundStatus {
+			return nil, fmt.Errorf("failed to run pre-activation hook: command not found (exit status %d)", notFoundStatus)
+		}
+		result.ExitCode = exitErr.ExitCode()
+		return result, fmt.Errorf("%w (exit status %d)", ErrRejected, result.ExitCode)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,92 @@
+package hook
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test relies on POSIX utilities")
+	}
+
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "tool")
+	if err := os.WriteFile(file, []byte("binary"), 0600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	t.Run("No hook configured", func(t *testing.T) {
+		result, err := Run("  ", file)
+		if result != nil || err != nil {
+			t.Errorf("expected nil result and error, got %v, %v", result, err)
+		}
+	})
+
+	t.Run("Hook passes", func(t *testing.T) {
+		result, err := Run("test -f {file}", file)
+		if err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		if result.ExitCode != 0 || result.Command != "test -f {file}" || result.RanAt.IsZero() {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("Path appended without placeholder", func(t *testing.T) {
+		if _, err := Run("test -d", file); !errors.Is(err, ErrRejected) {
+			t.Errorf("expected ErrRejected since %s is not a directory, got %v", file, err)
+		}
+	})
+
+	t.Run("Hook rejects", func(t *testing.T) {
+		result, err := Run("false", file)
+		if !errors.Is(err, ErrRejected) {
+			t.Fatalf("expected ErrRejected, got %v", err)
+		}
+		if result == nil || result.ExitCode != 1 {
+			t.Errorf("expected exit code 1 in result, got %+v", result)
+		}
+	})
+
+	t.Run("Hook cannot start", func(t *testing.T) {
+		_, err := Run("execman-no-such-scanner {file}", file)
+		if err == nil || errors.Is(err, ErrRejected) {
+			t.Errorf("expected a start failure, got %v", err)
+		}
+	})
+	t.Run("Quoted arguments and paths with spaces", func(t *testing.T) {
+		scriptDir := filepath.Join(tmpDir, "My Scripts")
+		binaryDir := filepath.Join(tmpDir, "Staged Files")
+		for _, dir := range []string{scriptDir, binaryDir} {
+			if err := os.Mkdir(dir, 0700); err != nil {
+				t.Fatalf("failed to create %s: %v", dir, err)
+			}
+		}
+		spaced := filepath.Join(binaryDir, "tool")
+		if err := os.WriteFile(spaced, []byte("binary"), 0600); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+		script := filepath.Join(scriptDir, "post.sh")
+		if err := os.WriteFile(script, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\" \"$EXECMAN_FILE\" > \"$HOOK_OUTPUT\"\n"), 0700); err != nil {
+			t.Fatalf("failed to create script: %v", err)
+		}
+		output := filepath.Join(tmpDir, "output")
+		t.Setenv("HOOK_OUTPUT", output)
+
+		if _, err := Run(`"`+script+`" --flag "two words" {file}`, spaced); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		got, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatalf("failed to read hook output: %v", err)
+		}
+		want := "--flag\ntwo words\n" + spaced + "\n" + spaced + "\n"
+		if string(got) != want {
+			t.Errorf("hook saw %q, want %q", got, want)
+		}
+	})
+}
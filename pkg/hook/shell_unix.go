@@ -0,0 +1,16 @@
+//go:build !windows
+
+package hook
+
+import "os/exec"
+
+// fileReference is how the command refers to the binary's path.
+const fileReference = `"$` + FileVariable + `"`
+
+// notFoundStatus is the exit status sh gives for a command it cannot find.
+const notFoundStatus = 127
+
+// shellCommand returns a command that runs script with sh.
+func shellCommand(script string) *exec.Cmd {
+	return exec.Command("/bin/sh", "-c", script)
+}
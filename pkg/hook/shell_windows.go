@@ -0,0 +1,28 @@
+//go:build windows
+
+package hook
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// fileReference is how the command refers to the binary's path.
+const fileReference = `"%` + FileVariable + `%"`
+
+// notFoundStatus is the exit status cmd gives for a command it cannot find.
+const notFoundStatus = 9009
+
+// shellCommand returns a command that runs script with cmd. The command line
+// is given as it is, because cmd does not follow the quoting rules that Go
+// would otherwise apply to its arguments.
+func shellCommand(script string) *exec.Cmd {
+	shell := os.Getenv("ComSpec")
+	if shell == "" {
+		shell = "cmd.exe"
+	}
+	cmd := exec.Command(shell)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd /S /C "` + script + `"`}
+	return cmd
+}
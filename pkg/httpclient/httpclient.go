@@ -0,0 +1,130 @@
+// Package httpclient provides the HTTP client that execman uses for every
+// API request and download, so that network settings apply to all of them:
+// timeouts, the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables,
+// extra certificate authorities, client certificates and download mirrors.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/config"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Defaults for the timeouts the config leaves unset.
+const (
+	DefaultConnectTimeout = 30 * time.Second
+	DefaultTimeout        = 10 * time.Minute
+)
+
+// client is the shared client. Until Configure is called it has the default
+// settings.
+var client, _ = New(&config.Config{})
+
+// Client returns the shared client.
+func Client() *http.Client {
+	return client
+}
+
+// Configure sets up the shared client from the config, sending requests to
+// hosts with a download mirror to the mirror.
+func Configure(cfg *config.Config) error {
+	c, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	rules, err := NewMirrorRules(cfg.Mirrors)
+	if err != nil {
+		return fmt.Errorf("invalid mirrors config: %w", err)
+	}
+	if rules != nil {
+		c.Transport = &MirrorTransport{Rules: rules, Base: c.Transport}
+	}
+	client = c
+	return nil
+}
+
+// New returns a client with the config's network settings, leaving out
+// download mirrors.
+func New(cfg *config.Config) (*http.Client, error) {
+	connectTimeout, err := duration(cfg.ConnectTimeout, DefaultConnectTimeout, "connect_timeout")
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := duration(cfg.Timeout, DefaultTimeout, "timeout")
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Read the proxy variables now rather than use http.ProxyFromEnvironment,
+	// which reads them only once per process.
+	proxy := httpproxy.FromEnvironment().ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+	transport.DialContext = (&net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// duration parses a configured duration, using def if it is unset.
+func duration(value string, def time.Duration, key string) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: use a duration such as 30s or 5m", key, value)
+	}
+	return d, nil
+}
+
+// newTLSConfig trusts the config's CA bundle as well as the system's
+// certificate authorities, and presents its client certificate, if any.
+func newTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		// #nosec G304 -- The CA bundle path comes from the user's own configuration
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca_bundle %s", cfg.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch {
+	case cfg.ClientCert != "" && cfg.ClientKey != "":
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	case cfg.ClientCert != "" || cfg.ClientKey != "":
+		return nil, fmt.Errorf("client_cert and client_key must be set together")
+	}
+	return tlsConfig, nil
+}
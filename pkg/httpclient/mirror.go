@@ -0,0 +1,115 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AnyHost is the mirror rule key that applies to hosts without a rule of
+// their own.
+const AnyHost = "*"
+
+// MirrorRules maps upstream hosts to the URL templates of a download mirror,
+// such as a caching proxy on the local network, that their requests are sent
+// to instead. Only requests are redirected: the URLs execman records stay the
+// upstream ones. A template may use {host}, the upstream host, and {path},
+// the upstream path without its leading slash and with any query string,
+// e.g. http://mirror.local:8080/{host}/{path}.
+type MirrorRules struct {
+	templates map[string]string
+}
+
+// NewMirrorRules checks templates and returns the rules, or nil if there are
+// none.
+func NewMirrorRules(templates map[string]string) (*MirrorRules, error) {
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	r := &MirrorRules{templates: make(map[string]string, len(templates))}
+	for host, template := range templates {
+		if host == "" {
+			return nil, fmt.Errorf("mirror rule has no host")
+		}
+		if !strings.Contains(template, "{path}") {
+			return nil, fmt.Errorf("mirror template for %s must contain {path}", host)
+		}
+		expanded, err := url.Parse(expand(template, "example.com", "a/b"))
+		if err != nil || (expanded.Scheme != "https" && expanded.Scheme != "http") || expanded.Host == "" {
+			return nil, fmt.Errorf("mirror template for %s must be an http or https URL: %s", host, template)
+		}
+		r.templates[strings.ToLower(host)] = template
+	}
+	return r, nil
+}
+
+// expand fills in a template's placeholders.
+func expand(template, host, path string) string {
+	return strings.NewReplacer("{host}", host, "{path}", path).Replace(template)
+}
+
+// Rewrite returns the mirror URL for u, or nil if u's host has no mirror.
+func (r *MirrorRules) Rewrite(u *url.URL) (*url.URL, error) {
+	if r == nil {
+		return nil, nil
+	}
+	host := strings.ToLower(u.Host)
+	template, found := r.templates[host]
+	if !found {
+		template, found = r.templates[strings.ToLower(u.Hostname())]
+	}
+	if !found {
+		template, found = r.templates[AnyHost]
+	}
+	if !found {
+		return nil, nil
+	}
+
+	path := strings.TrimPrefix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	rewritten, err := url.Parse(expand(template, host, path))
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirror URL for %s: %w", u, err)
+	}
+	// A request that is already for the mirror must not be sent to it again.
+	if strings.EqualFold(rewritten.Host, u.Host) {
+		return nil, nil
+	}
+	return rewritten, nil
+}
+
+// MirrorTransport is an http.RoundTripper that sends requests to their
+// mirror, if they have one. Responses keep the original request, so callers see the
+// upstream URL they asked for.
+type MirrorTransport struct {
+	Rules *MirrorRules
+	Base  http.RoundTripper // Nil means http.DefaultTransport.
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *MirrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	target, err := t.Rules.Rewrite(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return base.RoundTrip(req)
+	}
+
+	mirrored := req.Clone(req.Context())
+	mirrored.URL = target
+	mirrored.Host = target.Host
+	resp, err := base.RoundTrip(mirrored)
+	if err != nil {
+		return nil, fmt.Errorf("mirror %s: %w", target.Host, err)
+	}
+	resp.Request = req
+	return resp, nil
+}
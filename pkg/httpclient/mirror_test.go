@@ -0,0 +1,60 @@
+package httpclient
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMirrorRulesRewrite(t *testing.T) {
+	rules, err := NewMirrorRules(map[string]string{
+		"api.github.com": "https://mirror.local/github-api/{path}",
+		"*":              "http://mirror.local:8080/{host}/{path}",
+	})
+	if err != nil {
+		t.Fatalf("NewMirrorRules returned error: %v", err)
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "https://api.github.com/repos/o/r/releases", want: "https://mirror.local/github-api/repos/o/r/releases"},
+		{in: "https://github.com/o/r/releases/download/v1.0.0/r_linux_amd64.tar.gz", want: "http://mirror.local:8080/github.com/o/r/releases/download/v1.0.0/r_linux_amd64.tar.gz"},
+		{in: "https://releases.example.com/tool/index.json?channel=stable", want: "http://mirror.local:8080/releases.example.com/tool/index.json?channel=stable"},
+		{in: "http://mirror.local:8080/github.com/o/r", want: ""},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.in)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) returned error: %v", tt.in, err)
+		}
+		got, err := rules.Rewrite(u)
+		if err != nil {
+			t.Fatalf("Rewrite(%s) returned error: %v", tt.in, err)
+		}
+		gotString := ""
+		if got != nil {
+			gotString = got.String()
+		}
+		if gotString != tt.want {
+			t.Errorf("Rewrite(%s) = %q, want %q", tt.in, gotString, tt.want)
+		}
+	}
+}
+
+func TestNewMirrorRulesRejectsBadTemplates(t *testing.T) {
+	tests := map[string]map[string]string{
+		"no path":    {"github.com": "https://mirror.local/github"},
+		"not a URL":  {"github.com": "mirror.local/{path}"},
+		"bad scheme": {"github.com": "ftp://mirror.local/{path}"},
+		"no host":    {"": "https://mirror.local/{path}"},
+	}
+	for name, templates := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewMirrorRules(templates); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -48,26 +48,18 @@ func Run(opts Options) error {
 
 	// Create config.
 	fmt.Println("Creating configuration...")
-	cfg, err := config.Load()
-	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
-	}
-
-	cfg.DefaultInstallDir = absFolder
-
-	if err := cfg.Save(); err != nil {
+	if err := config.Update(func(cfg *config.Config) error {
+		cfg.DefaultInstallDir = absFolder
+		return nil
+	}); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 	fmt.Println("✓ Configuration created")
 
 	// Create registry.
 	fmt.Println("Creating registry...")
-	reg, err := registry.Load()
-	if err != nil {
-		return fmt.Errorf("failed to load registry: %w", err)
-	}
-
-	if err := reg.Save(); err != nil {
+	// Saving the registry unchanged creates it if it does not exist yet.
+	if err := registry.Update(func(*registry.Registry) error { return nil }); err != nil {
 		return fmt.Errorf("failed to save registry: %w", err)
 	}
 	fmt.Println("✓ Registry created")
@@ -2,6 +2,7 @@ package install
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,9 +11,15 @@ import (
 	"time"
 
 	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/atomicfile"
 	"github.com/sfkleach/execman/pkg/config"
 	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/gobuild"
+	"github.com/sfkleach/execman/pkg/hook"
+	"github.com/sfkleach/execman/pkg/policy"
 	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/source"
+	"github.com/sfkleach/execman/pkg/store"
 )
 
 // Options represents the install command options.
@@ -21,6 +28,48 @@ type Options struct {
 	Into               string
 	Yes                bool
 	IncludePrereleases bool
+	Layout             string // Empty means the configured layout.
+
+	// AssetName selects the release asset by name instead of by platform.
+	AssetName string
+	// ExpectedArchiveChecksum and ExpectedChecksum, if set, must match the
+	// downloaded asset and the extracted binary, as recorded in a lockfile.
+	ExpectedArchiveChecksum string
+	ExpectedChecksum        string
+
+	// ArchivePath installs a local release archive instead of downloading
+	// one, without any network access. Source must then include the
+	// version, and AssetName defaults to the archive's file name.
+	ArchivePath string
+	// Origin records where a local archive was originally downloaded from,
+	// if known, so that the registry entry matches a direct install.
+	Origin *Origin
+	// ChecksumsPath names a local checksums file that must list the local
+	// archive with a matching checksum.
+	ChecksumsPath string
+
+	// Version selects the release to install, as an alternative to a
+	// GitHub source's @version suffix. Empty means the latest.
+	Version string
+	// Name is the executable name. Empty means the name the source implies:
+	// a GitHub repository's name, or a URL template's file name.
+	Name string
+	// URLSource configures a Source that is a download URL template.
+	URLSource *registry.URLSource
+	// BuildFromSource builds a Go module with go install when its GitHub
+	// release has no asset for the platform.
+	BuildFromSource bool
+	// AllowMoved accepts a GitHub repository that has been renamed or
+	// transferred without asking, even with Yes.
+	AllowMoved bool
+}
+
+// Origin describes the release asset a local archive was downloaded from.
+type Origin struct {
+	RepoID         int64
+	AssetURL       string
+	ChecksumSource string
+	DownloadedAt   time.Time
 }
 
 // Run executes the install command.
@@ -36,11 +85,87 @@ func Run(opts Options) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Parse source.
-	owner, repo, version, err := github.ParseSource(opts.Source)
+	if opts.Layout == "" {
+		opts.Layout = cfg.Layout
+	}
+	if err := config.ValidateLayout(opts.Layout); err != nil {
+		return err
+	}
+
+	// Parse source. Sources other than GitHub repositories have a provider.
+	provider, err := source.Lookup(opts.Source, opts.URLSource)
 	if err != nil {
 		return err
 	}
+	var owner, repo, version, execName, sourceURL string
+	if provider != nil {
+		sourceURL = provider.Source()
+		execName = opts.Name
+		if execName == "" {
+			execName = provider.Name()
+		}
+		if execName == "" {
+			return fmt.Errorf("cannot tell the executable name from %s; give it with --name", sourceURL)
+		}
+	} else {
+		if opts.URLSource != nil {
+			return fmt.Errorf("latest-version and checksums URLs only apply to download URL templates, not %s", opts.Source)
+		}
+		owner, repo, version, err = github.ParseSource(opts.Source)
+		if err != nil {
+			return err
+		}
+		sourceURL = github.ToURL(owner, repo)
+	}
+	if opts.Version != "" {
+		if version != "" && version != opts.Version {
+			return fmt.Errorf("conflicting versions %s and %s", version, opts.Version)
+		}
+		version = opts.Version
+	}
+
+	// Refuse sources that the allowlist/denylist policy blocks.
+	if err := policy.Enforce(sourceURL); err != nil {
+		return err
+	}
+
+	local := opts.ArchivePath != ""
+	var repoID int64
+	switch {
+	case local:
+		// Without the network there is no release to find the version from.
+		if version == "" {
+			return fmt.Errorf("a version is required to install %s from a local archive", sourceURL)
+		}
+		if opts.Origin != nil {
+			repoID = opts.Origin.RepoID
+		}
+	case provider != nil:
+		// Only GitHub sources have a repository to resolve.
+	default:
+		// Resolve the repository so that a renamed or transferred repository
+		// is recorded under its current name, together with its permanent ID.
+		repository, err := github.GetRepository(owner, repo)
+		if err != nil {
+			return err
+		}
+		if repository.Changed(owner, repo, 0) {
+			confirmed, err := ConfirmMoved(owner, repo, 0, repository, "installing", opts.Yes, opts.AllowMoved)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Println("Installation cancelled.")
+				return nil
+			}
+			owner, repo = repository.Owner(), repository.Name()
+			sourceURL = github.ToURL(owner, repo)
+			if err := policy.Enforce(sourceURL); err != nil {
+				return err
+			}
+		}
+		repoID = repository.ID
+	}
 
 	// Use config defaults if not specified.
 	if opts.Into == "" {
@@ -52,10 +177,18 @@ func Run(opts Options) error {
 
 	// Fetch release.
 	var release *github.Release
-	if version != "" {
+	switch {
+	case local:
+		fmt.Printf("Installing %s %s from %s...\n", sourceURL, version, opts.ArchivePath)
+	case provider != nil && version == "":
+		fmt.Printf("Fetching latest version of %s...\n", execName)
+		version, err = provider.Latest(opts.IncludePrereleases)
+	case provider != nil:
+		fmt.Printf("Installing %s %s...\n", execName, version)
+	case version != "":
 		fmt.Printf("Fetching release %s from %s/%s...\n", version, owner, repo)
 		release, err = github.GetRelease(owner, repo, version)
-	} else {
+	default:
 		fmt.Printf("Fetching latest release from %s/%s...\n", owner, repo)
 		release, err = github.GetLatestRelease(owner, repo, opts.IncludePrereleases)
 	}
@@ -69,7 +202,12 @@ func Run(opts Options) error {
 	}
 
 	// Check if already installed.
-	execName := repo
+	if provider == nil {
+		execName = opts.Name
+		if execName == "" {
+			execName = repo
+		}
+	}
 	if existing, found := reg.Get(execName); found {
 		if existing.Version == version {
 			fmt.Printf("Warning: %s version %s is already installed at %s\n", execName, version, existing.Path)
@@ -89,10 +227,17 @@ func Run(opts Options) error {
 	// Confirm installation.
 	targetPath := filepath.Join(opts.Into, execName)
 	fmt.Printf("\nInstallation Details:\n")
-	fmt.Printf("  Repository: %s\n", github.ToURL(owner, repo))
+	if provider != nil {
+		fmt.Printf("  Source:     %s\n", sourceURL)
+	} else {
+		fmt.Printf("  Repository: %s\n", sourceURL)
+	}
 	fmt.Printf("  Version:    %s\n", version)
 	fmt.Printf("  Platform:   %s/%s\n", runtime.GOOS, runtime.GOARCH)
 	fmt.Printf("  Target:     %s\n", targetPath)
+	if opts.Layout == registry.LayoutVersioned {
+		fmt.Printf("  Layout:     %s (linked to %s)\n", opts.Layout, store.Path(cfg.StoreDir, execName, version))
+	}
 
 	if !opts.Yes {
 		fmt.Print("\nProceed with installation? (Y/n): ")
@@ -105,18 +250,6 @@ func Run(opts Options) error {
 		}
 	}
 
-	// Find matching asset.
-	fmt.Println("\nFinding matching asset...")
-	asset, err := github.FindAsset(release.Assets, runtime.GOOS, runtime.GOARCH)
-	if err != nil {
-		fmt.Println("\nAvailable assets:")
-		for _, a := range release.Assets {
-			fmt.Printf("  - %s\n", a.Name)
-		}
-		return fmt.Errorf("no matching asset found for %s/%s", runtime.GOOS, runtime.GOARCH)
-	}
-	fmt.Printf("Found: %s\n", asset.Name)
-
 	// Create temp directory for download.
 	tempDir, err := os.MkdirTemp("", "execman-*")
 	if err != nil {
@@ -124,39 +257,107 @@ func Run(opts Options) error {
 	}
 	defer os.RemoveAll(tempDir)
 
-	archivePath := filepath.Join(tempDir, asset.Name)
-
-	// Download asset.
-	fmt.Printf("\nDownloading %s...\n", asset.Name)
-	if err := github.DownloadAsset(asset, archivePath); err != nil {
-		return err
-	}
-	fmt.Println("Download complete.")
-
-	// Try to download and verify checksum (optional, won't fail if not available).
-	checksumPath := filepath.Join(tempDir, "checksums.txt")
-	var expectedChecksum string
-	for _, a := range release.Assets {
-		if strings.Contains(strings.ToLower(a.Name), "checksum") ||
-			strings.HasSuffix(strings.ToLower(a.Name), ".sha256") {
-			fmt.Println("\nDownloading checksums...")
-			if err := github.DownloadAsset(&a, checksumPath); err == nil {
-				checksum, err := archive.FindChecksumInFile(checksumPath, asset.Name)
-				if err == nil {
-					expectedChecksum = checksum
-					fmt.Println("Verifying checksum...")
-					archiveChecksum, err := archive.CalculateChecksum(archivePath)
-					if err != nil {
-						return fmt.Errorf("failed to calculate checksum: %w", err)
-					}
-					if archiveChecksum != expectedChecksum {
-						return fmt.Errorf("checksum verification failed")
-					}
-					fmt.Println("Checksum verified.")
-				}
+	var asset *github.Asset
+	var archivePath string
+	var provenance *Provenance
+	var built *gobuild.Result
+	switch {
+	case local:
+		archivePath = opts.ArchivePath
+		asset, provenance, err = localArchive(opts)
+		if err != nil {
+			return err
+		}
+	case provider != nil:
+		fmt.Println()
+		download, err := provider.Download(version, runtime.GOOS, runtime.GOARCH, tempDir)
+		if err != nil {
+			return err
+		}
+		archivePath = download.Path
+		asset = &github.Asset{Name: download.AssetName, BrowserDownloadURL: download.AssetURL}
+		provenance = &Provenance{
+			ArchiveChecksum: download.ArchiveChecksum,
+			ChecksumSource:  download.ChecksumSource,
+			DownloadedAt:    download.DownloadedAt,
+		}
+		fmt.Println("Download complete.")
+	default:
+		// Find matching asset.
+		fmt.Println("\nFinding matching asset...")
+		if opts.AssetName != "" {
+			asset, err = findAssetByName(release.Assets, opts.AssetName)
+		} else {
+			asset, err = github.FindAsset(release.Assets, runtime.GOOS, runtime.GOARCH)
+		}
+		if opts.BuildFromSource && opts.AssetName == "" && errors.Is(err, github.ErrNoMatchingAsset) {
+			fmt.Printf("%s %s has no asset for %s/%s; building it from source.\n", repo, version, runtime.GOOS, runtime.GOARCH)
+			built, err = gobuild.Build(gobuild.Candidates(owner, repo, version, ""), version, tempDir)
+			if err != nil {
+				return err
 			}
+			archivePath = built.Path
+			asset = &github.Asset{}
+			provenance = &Provenance{}
 			break
 		}
+		if err != nil {
+			fmt.Println("\nAvailable assets:")
+			for _, a := range release.Assets {
+				fmt.Printf("  - %s\n", a.Name)
+			}
+			if errors.Is(err, github.ErrNoMatchingAsset) && opts.AssetName == "" {
+				fmt.Println("\nIf this is a Go module, --build-from-source builds it with go install instead.")
+			}
+			return err
+		}
+		fmt.Printf("Found: %s\n", asset.Name)
+
+		archivePath = filepath.Join(tempDir, asset.Name)
+
+		// Download asset.
+		fmt.Printf("\nDownloading %s...\n", asset.Name)
+		if err := github.DownloadAsset(asset, archivePath); err != nil {
+			return err
+		}
+		fmt.Println("Download complete.")
+
+		// Verify the download against the release's checksums file, if any.
+		provenance, err = VerifyDownload(release, asset, archivePath, tempDir)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.ExpectedArchiveChecksum != "" && provenance.ArchiveChecksum != opts.ExpectedArchiveChecksum {
+		return fmt.Errorf("archive checksum mismatch for %s: expected %s, got %s", asset.Name, opts.ExpectedArchiveChecksum, provenance.ArchiveChecksum)
+	}
+
+	// Extract binary into the temp directory, so that nothing reaches the
+	// install directory until it has passed the pre-activation hook.
+	fmt.Println("\nExtracting binary...")
+	stagedDir := filepath.Join(tempDir, "staged")
+	if err := os.Mkdir(stagedDir, 0700); err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	stagedPath := filepath.Join(stagedDir, execName)
+	if err := archive.ExtractBinaryWithLimits(archivePath, stagedPath, cfg.ExtractLimits()); err != nil {
+		return fmt.Errorf("failed to extract binary: %w", err)
+	}
+
+	// Calculate checksum of the binary.
+	fmt.Println("Calculating checksum of binary...")
+	checksum, err := archive.CalculateChecksum(stagedPath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+	if opts.ExpectedChecksum != "" && checksum != opts.ExpectedChecksum {
+		return fmt.Errorf("binary checksum mismatch for %s: expected %s, got %s", execName, opts.ExpectedChecksum, checksum)
+	}
+
+	// Let the user's scanner veto the binary before it is activated.
+	hookResult, err := hook.Run(cfg.PreActivationHook, stagedPath)
+	if err != nil {
+		return err
 	}
 
 	// Ensure target directory exists.
@@ -165,35 +366,228 @@ func Run(opts Options) error {
 		return fmt.Errorf("failed to create target directory: %w", err)
 	}
 
-	// Extract binary.
-	fmt.Println("\nExtracting binary...")
-	if err := archive.ExtractBinary(archivePath, targetPath); err != nil {
-		return fmt.Errorf("failed to extract binary: %w", err)
+	// Keep the current version of a copy-layout install in the store, as
+	// update does, so that it can be rolled back to.
+	var kept string
+	if existing, found := reg.Get(execName); found && !existing.Versioned() && existing.Version != version && cfg.KeepVersions > 0 {
+		if _, err := os.Stat(existing.Path); err == nil {
+			fmt.Printf("Keeping %s %s for rollback...\n", execName, existing.Version)
+			kept, err = store.Save(cfg.StoreDir, execName, existing.Version, existing.Path)
+			if err != nil {
+				return fmt.Errorf("failed to keep previous version: %w", err)
+			}
+		}
 	}
 
-	// Calculate checksum of installed binary.
-	fmt.Println("Calculating checksum of installed binary...")
-	checksum, err := archive.CalculateChecksum(targetPath)
-	if err != nil {
-		return fmt.Errorf("failed to calculate checksum: %w", err)
+	fmt.Println("Installing binary...")
+	var replacement *atomicfile.Replacement
+	if opts.Layout == registry.LayoutVersioned {
+		// The binary lives in the store and the install directory only
+		// holds a link to it, so other versions can sit alongside.
+		storedPath, err := store.Save(cfg.StoreDir, execName, version, stagedPath)
+		if err != nil {
+			return fmt.Errorf("failed to install binary: %w", err)
+		}
+		replacement, err = atomicfile.ReplaceSymlink(storedPath, targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to link binary: %w", err)
+		}
+	} else {
+		replacement, err = atomicfile.ReplaceExecutable(stagedPath, targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to install binary: %w", err)
+		}
 	}
 
 	// Register executable.
 	fmt.Println("Updating registry...")
 	platformStr := fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
-	reg.Add(execName, &registry.Executable{
-		Source:      github.ToURL(owner, repo),
+	entry := &registry.Executable{
+		Source:      sourceURL,
 		Version:     version,
 		InstalledAt: time.Now(),
 		Path:        targetPath,
 		Platform:    platformStr,
 		Checksum:    checksum,
-	})
+		RepoID:      repoID,
+
+		AssetName:       asset.Name,
+		AssetURL:        asset.BrowserDownloadURL,
+		ArchiveChecksum: provenance.ArchiveChecksum,
+		ChecksumSource:  provenance.ChecksumSource,
+		DownloadedAt:    provenance.DownloadedAt,
+		Hook:            hookResult,
+	}
+	if opts.Layout == registry.LayoutVersioned {
+		entry.Layout = registry.LayoutVersioned
+	}
+	if provider != nil {
+		entry.URLSource = opts.URLSource
+	}
+	if built != nil {
+		entry.BuiltFrom = built.Package
+	}
 
-	if err := reg.Save(); err != nil {
+	// Reinstalling keeps the version history. A versioned install's previous
+	// version is still in the store, and a copy-layout install's was kept
+	// there above, so it joins the history too.
+	var dropped []*registry.Executable
+	if err := registry.Update(func(r *registry.Registry) error {
+		dropped = nil
+		if previous, found := r.Get(execName); found {
+			entry.History = append([]*registry.Executable(nil), previous.History...)
+			if old, ok := entry.RemoveHistory(version); ok && !entry.Versioned() {
+				dropped = append(dropped, old)
+			}
+			if previous.Versioned() && previous.Version != version {
+				stored := store.Path(cfg.StoreDir, execName, previous.Version)
+				dropped = append(dropped, entry.PushHistory(previous.Snapshot(stored), cfg.KeepVersions)...)
+			} else if kept != "" && previous.Version != version {
+				dropped = append(dropped, entry.PushHistory(previous.Snapshot(kept), cfg.KeepVersions)...)
+			}
+		}
+		r.Add(execName, entry)
+		return nil
+	}); err != nil {
+		if restoreErr := replacement.Restore(); restoreErr != nil {
+			return fmt.Errorf("failed to save registry: %w (and failed to restore previous file: %v)", err, restoreErr)
+		}
 		return fmt.Errorf("failed to save registry: %w", err)
 	}
+	if err := replacement.Commit(); err != nil {
+		fmt.Printf("Warning: failed to remove previous executable: %v\n", err)
+	}
+	for _, old := range dropped {
+		if err := store.Delete(cfg.StoreDir, execName, old.Version); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
 
 	fmt.Printf("\n✓ Successfully installed %s %s to %s\n", execName, version, targetPath)
 	return nil
 }
+
+// findAssetByName returns the release asset with the given name.
+func findAssetByName(assets []github.Asset, name string) (*github.Asset, error) {
+	for i := range assets {
+		if assets[i].Name == name {
+			return &assets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("release has no asset named %s", name)
+}
+
+// localArchive describes a local archive as the release asset it came from.
+func localArchive(opts Options) (*github.Asset, *Provenance, error) {
+	asset := &github.Asset{Name: opts.AssetName}
+	if asset.Name == "" {
+		asset.Name = filepath.Base(opts.ArchivePath)
+	}
+
+	archiveChecksum, err := archive.CalculateChecksum(opts.ArchivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+	provenance := &Provenance{
+		ArchiveChecksum: archiveChecksum,
+		DownloadedAt:    time.Now(),
+	}
+	if opts.Origin != nil {
+		asset.BrowserDownloadURL = opts.Origin.AssetURL
+		provenance.ChecksumSource = opts.Origin.ChecksumSource
+		if !opts.Origin.DownloadedAt.IsZero() {
+			provenance.DownloadedAt = opts.Origin.DownloadedAt
+		}
+	}
+
+	// Unlike a release's checksums file, one named by the user must list the
+	// archive.
+	if opts.ChecksumsPath != "" {
+		expectedChecksum, err := archive.FindChecksumInFile(opts.ChecksumsPath, asset.Name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to verify %s: %w", asset.Name, err)
+		}
+		fmt.Println("Verifying checksum...")
+		if archiveChecksum != expectedChecksum {
+			return nil, nil, fmt.Errorf("checksum verification failed: %s lists %s, got %s", opts.ChecksumsPath, expectedChecksum, archiveChecksum)
+		}
+		absPath, err := filepath.Abs(opts.ChecksumsPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		provenance.ChecksumSource = "file://" + filepath.ToSlash(absPath)
+		fmt.Println("Checksum verified.")
+	}
+	return asset, provenance, nil
+}
+
+// Provenance records where a downloaded archive came from and how it was
+// verified.
+type Provenance struct {
+	ArchiveChecksum string
+	ChecksumSource  string // Empty if the release publishes no usable checksums file.
+	DownloadedAt    time.Time
+}
+
+// VerifyDownload calculates the checksum of a downloaded archive and, if the
+// release publishes a checksums file listing the asset, verifies it. A missing
+// checksums file is not an error, but a mismatch is.
+func VerifyDownload(release *github.Release, asset *github.Asset, archivePath, tempDir string) (*Provenance, error) {
+	archiveChecksum, err := archive.CalculateChecksum(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+	provenance := &Provenance{
+		ArchiveChecksum: archiveChecksum,
+		DownloadedAt:    time.Now(),
+	}
+
+	checksumPath := filepath.Join(tempDir, "checksums.txt")
+	for _, a := range release.Assets {
+		if strings.Contains(strings.ToLower(a.Name), "checksum") ||
+			strings.HasSuffix(strings.ToLower(a.Name), ".sha256") {
+			fmt.Println("\nDownloading checksums...")
+			if err := github.DownloadAsset(&a, checksumPath); err == nil {
+				expectedChecksum, err := archive.FindChecksumInFile(checksumPath, asset.Name)
+				if err == nil {
+					fmt.Println("Verifying checksum...")
+					if archiveChecksum != expectedChecksum {
+						return nil, fmt.Errorf("checksum verification failed")
+					}
+					provenance.ChecksumSource = a.BrowserDownloadURL
+					fmt.Println("Checksum verified.")
+				}
+			}
+			break
+		}
+	}
+
+	return provenance, nil
+}
+
+// ConfirmMoved warns that the GitHub repository owner/repo has been renamed
+// or transferred, so that it may now belong to someone else, and asks
+// whether to carry on with action, such as "installing", from its new
+// location. With yes, only allowMoved counts as confirmation.
+func ConfirmMoved(owner, repo string, recordedID int64, repository *github.Repository, action string, yes, allowMoved bool) (bool, error) {
+	fmt.Printf("Warning: the upstream repository %s/%s has changed.\n", owner, repo)
+	fmt.Printf("  Recorded:  %s/%s", owner, repo)
+	if recordedID != 0 {
+		fmt.Printf(" (id %d)", recordedID)
+	}
+	fmt.Println()
+	fmt.Printf("  Now:       %s (id %d)\n", repository.FullName, repository.ID)
+
+	if allowMoved {
+		return true, nil
+	}
+	if yes {
+		return false, fmt.Errorf("upstream repository moved to %s\n       Cannot proceed in non-interactive mode.\n       Run without --yes to confirm, or pass --allow-moved", repository.FullName)
+	}
+
+	fmt.Printf("Continue %s from %s? [y/N]: ", action, repository.FullName)
+	reader := bufio.NewReader(os.Stdin)
+	response, _ := reader.ReadString('\n')
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes", nil
+}
@@ -0,0 +1,187 @@
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+func TestLocalArchiveChecksums(t *testing.T) {
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "tool_linux_amd64.tar.gz")
+	if err := os.WriteFile(archivePath, []byte("archive"), 0600); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	checksum, err := archive.CalculateChecksum(archivePath)
+	if err != nil {
+		t.Fatalf("CalculateChecksum returned error: %v", err)
+	}
+	sum := strings.TrimPrefix(checksum, "sha256:")
+	wrong := strings.Repeat("0", len(sum))
+
+	tests := []struct {
+		name      string
+		checksums string
+		wantErr   string
+	}{
+		{name: "no checksums file"},
+		{name: "listed", checksums: sum + "  tool_linux_amd64.tar.gz\n"},
+		{name: "mismatch", checksums: wrong + "  tool_linux_amd64.tar.gz\n", wantErr: "verification failed"},
+		{name: "not listed", checksums: sum + "  other.tar.gz\n", wantErr: "not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{ArchivePath: archivePath}
+			if tt.checksums != "" {
+				opts.ChecksumsPath = filepath.Join(t.TempDir(), "checksums.txt")
+				if err := os.WriteFile(opts.ChecksumsPath, []byte(tt.checksums), 0600); err != nil {
+					t.Fatalf("WriteFile returned error: %v", err)
+				}
+			}
+
+			asset, provenance, err := localArchive(opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("localArchive returned error: %v", err)
+			}
+			if asset.Name != "tool_linux_amd64.tar.gz" {
+				t.Errorf("expected the asset to be named after the archive, got %q", asset.Name)
+			}
+			if (provenance.ChecksumSource != "") != (tt.checksums != "") {
+				t.Errorf("unexpected checksum source %q", provenance.ChecksumSource)
+			}
+		})
+	}
+}
+
+func TestRunRefusesMovedRepositoryWithYes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/acme/oldtool" {
+			_, _ = w.Write([]byte(`{"id": 7, "full_name": "newowner/tool"}`))
+			return
+		}
+		t.Errorf("unexpected request to %s", r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	savedBaseURL := github.APIBaseURL
+	github.APIBaseURL = server.URL
+	defer func() { github.APIBaseURL = savedBaseURL }()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	err := Run(Options{Source: "acme/oldtool", Into: t.TempDir(), Yes: true})
+	if err == nil || !strings.Contains(err.Error(), "--allow-moved") {
+		t.Fatalf("expected an error suggesting --allow-moved, got %v", err)
+	}
+	reg, err := registry.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(reg.Executables) != 0 {
+		t.Errorf("expected nothing to be installed, got %v", reg.Executables)
+	}
+}
+
+// releaseArchive builds a tar.gz holding a single executable named tool.
+func releaseArchive(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	if err := tw.WriteHeader(&tar.Header{Name: "tool", Mode: 0755, Size: int64(len(content))}); err != nil {
+		t.Fatalf("WriteHeader returned error: %v", err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRunKeepsCopyLayoutPreviousVersion(t *testing.T) {
+	asset := releaseArchive(t, "new binary")
+	assetName := "tool_" + runtime.GOOS + "_" + runtime.GOARCH + ".tar.gz"
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/tool":
+			_, _ = w.Write([]byte(`{"id": 7, "full_name": "acme/tool"}`))
+		case "/repos/acme/tool/releases/tags/v2.0.0":
+			_, _ = w.Write([]byte(`{"tag_name": "v2.0.0", "assets": [{"name": "` + assetName + `", "browser_download_url": "` + server.URL + `/download/asset"}]}`))
+		case "/download/asset":
+			_, _ = w.Write(asset)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	savedBaseURL := github.APIBaseURL
+	github.APIBaseURL = server.URL
+	defer func() { github.APIBaseURL = savedBaseURL }()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	into := filepath.Join(home, "bin")
+	path := filepath.Join(into, "tool")
+	if err := os.MkdirAll(into, 0755); err != nil {
+		t.Fatalf("MkdirAll returned error: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("old binary"), 0755); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Add("tool", &registry.Executable{Source: "https://github.com/acme/tool", RepoID: 7, Version: "v1.0.0", Path: path})
+		return nil
+	}); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	if err := Run(Options{Source: "acme/tool", Version: "v2.0.0", Into: into, Yes: true}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	reg, err := registry.Load()
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	exec, found := reg.Get("tool")
+	if !found {
+		t.Fatal("expected tool to be registered")
+	}
+	if exec.Version != "v2.0.0" || len(exec.History) != 1 || exec.History[0].Version != "v1.0.0" {
+		t.Fatalf("expected v2.0.0 with v1.0.0 in its history, got %+v", exec)
+	}
+	if data, err := os.ReadFile(exec.History[0].Path); err != nil || string(data) != "old binary" {
+		t.Errorf("expected the previous binary to be kept, got %q (%v)", data, err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "new binary" {
+		t.Errorf("expected the new binary to be installed, got %q (%v)", data, err)
+	}
+}
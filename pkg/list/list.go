@@ -6,6 +6,7 @@ import (
 	"os"
 	"slices"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/sfkleach/execman/pkg/registry"
@@ -26,6 +27,23 @@ type ExecutableInfo struct {
 	Platform    string `json:"platform,omitempty"`
 	Checksum    string `json:"checksum,omitempty"`
 	InstalledAt string `json:"installed_at"`
+
+	AssetName       string `json:"asset_name,omitempty"`
+	AssetURL        string `json:"asset_url,omitempty"`
+	ArchiveChecksum string `json:"archive_checksum,omitempty"`
+	ChecksumSource  string `json:"checksum_source,omitempty"`
+	DownloadedAt    string `json:"downloaded_at,omitempty"`
+
+	Hook *registry.HookResult `json:"pre_activation_hook,omitempty"`
+
+	Layout        string `json:"layout,omitempty"`
+	SymlinkPolicy string `json:"symlink_policy,omitempty"`
+
+	URLSource *registry.URLSource `json:"url_source,omitempty"`
+	BuiltFrom string              `json:"built_from,omitempty"`
+
+	// History lists the previous versions kept for rollback, most recent first.
+	History []string `json:"history,omitempty"`
 }
 
 // NewListCommand creates the list command.
@@ -111,7 +129,25 @@ func outputJSON(reg *registry.Registry, names []string) error {
 			Source:      exec.Source,
 			Version:     exec.Version,
 			Path:        exec.Path,
+			Platform:    exec.Platform,
+			Checksum:    exec.Checksum,
 			InstalledAt: exec.InstalledAt.Format(time.RFC3339),
+
+			AssetName:       exec.AssetName,
+			AssetURL:        exec.AssetURL,
+			ArchiveChecksum: exec.ArchiveChecksum,
+			ChecksumSource:  exec.ChecksumSource,
+			Hook:            exec.Hook,
+			Layout:          exec.Layout,
+			SymlinkPolicy:   exec.SymlinkPolicy,
+			URLSource:       exec.URLSource,
+			BuiltFrom:       exec.BuiltFrom,
+		}
+		if !exec.DownloadedAt.IsZero() {
+			info.DownloadedAt = exec.DownloadedAt.Format(time.RFC3339)
+		}
+		for _, old := range exec.History {
+			info.History = append(info.History, old.Version)
 		}
 
 		executables = append(executables, info)
@@ -143,7 +179,7 @@ func outputCompactFormat(names []string) error {
 // outputLongFormat prints detailed information with labeled key-value pairs.
 // Each executable is separated by a blank line.
 func outputLongFormat(reg *registry.Registry, names []string) error {
-	const labelWidth = 16 // Width for left-aligned labels including colon.
+	const labelWidth = 18 // Width for left-aligned labels including colon.
 
 	for i, name := range names {
 		exec, ok := reg.Get(name)
@@ -161,6 +197,48 @@ func outputLongFormat(reg *registry.Registry, names []string) error {
 		fmt.Printf("%-*s%s\n", labelWidth, "Version:", exec.Version)
 		fmt.Printf("%-*s%s\n", labelWidth, "Path:", exec.Path)
 		fmt.Printf("%-*s%s\n", labelWidth, "Installed at:", exec.InstalledAt.Format(time.RFC3339))
+
+		// Entries migrated from schema 1 have no asset provenance, so only
+		// show what is known.
+		optional := []struct{ label, value string }{
+			{"Platform:", exec.Platform},
+			{"Checksum:", exec.Checksum},
+			{"Asset:", exec.AssetName},
+			{"Asset URL:", exec.AssetURL},
+			{"Archive checksum:", exec.ArchiveChecksum},
+			{"Checksum source:", exec.ChecksumSource},
+			{"Built from:", exec.BuiltFrom},
+			{"Layout:", exec.Layout},
+			{"Symlink policy:", exec.SymlinkPolicy},
+		}
+		if exec.URLSource != nil {
+			latest := exec.URLSource.LatestURL
+			if exec.URLSource.LatestPath != "" {
+				latest += " (" + exec.URLSource.LatestPath + ")"
+			}
+			optional = append(optional,
+				struct{ label, value string }{"Latest from:", latest},
+				struct{ label, value string }{"Checksums URL:", exec.URLSource.ChecksumsURL})
+		}
+		if !exec.DownloadedAt.IsZero() {
+			optional = append(optional, struct{ label, value string }{"Downloaded at:", exec.DownloadedAt.Format(time.RFC3339)})
+		}
+		if exec.Hook != nil {
+			hookSummary := fmt.Sprintf("%s (exit %d, %s)", exec.Hook.Command, exec.Hook.ExitCode, exec.Hook.RanAt.Format(time.RFC3339))
+			optional = append(optional, struct{ label, value string }{"Hook:", hookSummary})
+		}
+		if len(exec.History) > 0 {
+			versions := make([]string, 0, len(exec.History))
+			for _, old := range exec.History {
+				versions = append(versions, old.Version)
+			}
+			optional = append(optional, struct{ label, value string }{"History:", strings.Join(versions, ", ")})
+		}
+		for _, field := range optional {
+			if field.value != "" {
+				fmt.Printf("%-*s%s\n", labelWidth, field.label, field.value)
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,83 @@
+// Package lock provides an advisory, cross-process file lock used to
+// serialise changes to execman's registry and configuration.
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pollInterval is how often a waiting process retries the lock.
+const pollInterval = 50 * time.Millisecond
+
+// Lock is a held lock. It is released by Release or when the process exits.
+type Lock struct {
+	file *os.File
+}
+
+// HeldError is returned when the lock could not be acquired before the
+// timeout because another process holds it.
+type HeldError struct {
+	PID  int // Zero if the holder's PID could not be determined.
+	Path string
+}
+
+func (e *HeldError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("another execman process is running (lock %s is held)", e.Path)
+	}
+	return fmt.Sprintf("another execman process is running (pid %d)", e.PID)
+}
+
+// Acquire takes an exclusive lock on the file at path, creating it if needed,
+// waiting up to timeout for another holder to release it. The holder's PID is
+// written to the file so that waiters can report who they are waiting for.
+func Acquire(path string, timeout time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := tryLock(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if file != nil {
+			// The PID is informational only, so failing to record it is not
+			// a reason to give up the lock.
+			if err := file.Truncate(0); err == nil {
+				_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+			}
+			return &Lock{file: file}, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, &HeldError{PID: readPID(path), Path: path}
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Release releases the lock.
+func (l *Lock) Release() error {
+	return l.file.Close()
+}
+
+// readPID returns the PID recorded in the lock file, or zero.
+func readPID(path string) int {
+	// #nosec G304 -- Reading execman's own lock file
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,56 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireTimesOutWithHolderPID(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "execman.lock")
+
+	held, err := Acquire(lockPath, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+
+	_, err = Acquire(lockPath, 100*time.Millisecond)
+	var heldErr *HeldError
+	if !errors.As(err, &heldErr) {
+		t.Fatalf("expected HeldError, got %v", err)
+	}
+	if heldErr.PID != os.Getpid() {
+		t.Errorf("expected pid %d, got %d", os.Getpid(), heldErr.PID)
+	}
+
+	if err := held.Release(); err != nil {
+		t.Fatalf("Release returned error: %v", err)
+	}
+
+	again, err := Acquire(lockPath, time.Second)
+	if err != nil {
+		t.Fatalf("expected lock to be free after release, got %v", err)
+	}
+	_ = again.Release()
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "nested", "execman.lock")
+
+	held, err := Acquire(lockPath, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire returned error: %v", err)
+	}
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = held.Release()
+	}()
+
+	waiter, err := Acquire(lockPath, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected waiter to acquire the lock, got %v", err)
+	}
+	_ = waiter.Release()
+}
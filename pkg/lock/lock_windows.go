@@ -0,0 +1,38 @@
+//go:build windows
+
+package lock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is ERROR_SHARING_VIOLATION, which the syscall package
+// does not export.
+const errorSharingViolation syscall.Errno = 32
+
+// tryLock opens the lock file without write sharing, so that only one process
+// can hold it open for writing at a time. Readers are still allowed so that
+// waiters can read the holder's PID. It returns a nil file and nil error if
+// another process holds the lock.
+func tryLock(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := syscall.CreateFile(name,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		syscall.FILE_SHARE_READ,
+		nil,
+		syscall.OPEN_ALWAYS,
+		syscall.FILE_ATTRIBUTE_NORMAL,
+		0)
+	if err != nil {
+		if errors.Is(err, errorSharingViolation) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(handle), path), nil
+}
@@ -0,0 +1,187 @@
+package lockfile
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/install"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+// NewLockCommand creates the lock command.
+func NewLockCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock [lockfile]",
+		Short: "Write a lockfile pinning the exact installed releases",
+		Long: `Write a lockfile (execman-lock.json by default) recording, for every managed executable,
+its source, release tag, asset name and the checksums of the asset and the binary.
+Install the same binaries elsewhere with 'execman restore'.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := DefaultPath
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return runLock(path)
+		},
+	}
+
+	return cmd
+}
+
+func runLock(path string) error {
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	lf, skipped := FromRegistry(reg)
+
+	names := make([]string, 0, len(skipped))
+	for name := range skipped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("Warning: not locking %s: %s\n", name, skipped[name])
+	}
+
+	if err := lf.Save(path); err != nil {
+		return err
+	}
+	fmt.Printf("Locked %d executable(s) in %s\n", len(lf.Executables), path)
+	return nil
+}
+
+// RestoreOptions for the restore command.
+type RestoreOptions struct {
+	Path string
+	Into string // Empty means each executable's current directory or the default.
+}
+
+// NewRestoreCommand creates the restore command.
+func NewRestoreCommand() *cobra.Command {
+	var into string
+
+	cmd := &cobra.Command{
+		Use:   "restore [lockfile]",
+		Short: "Install the exact releases pinned by a lockfile",
+		Long: `Install the release assets recorded in a lockfile (execman-lock.json by default).
+Each download and extracted binary must match the locked checksums; any difference
+is an error and the executable is left as it was. Executables that already match
+are not downloaded again.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := RestoreOptions{
+				Path: DefaultPath,
+				Into: into,
+			}
+			if len(args) > 0 {
+				opts.Path = args[0]
+			}
+			return Restore(opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&into, "into", "d", "", "Install newly restored executables to this directory")
+
+	return cmd
+}
+
+// Restore executes the restore command.
+func Restore(opts RestoreOptions) error {
+	lf, err := Load(opts.Path)
+	if err != nil {
+		return err
+	}
+
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	restored, unchanged, failed := 0, 0, 0
+	for _, name := range lf.Names() {
+		entry := lf.Executables[name]
+		fmt.Printf("\n%s %s\n", name, entry.Version)
+
+		done, err := restoreOne(name, entry, reg, cfg, opts)
+		switch {
+		case err != nil:
+			fmt.Printf("Failed to restore %s: %v\n", name, err)
+			failed++
+		case done:
+			restored++
+		default:
+			unchanged++
+		}
+	}
+
+	fmt.Printf("\n%d restored, %d already matching, %d failed.\n", restored, unchanged, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d executables could not be restored", failed, len(lf.Executables))
+	}
+	return nil
+}
+
+// restoreOne installs one locked executable unless it already matches,
+// reporting whether anything was installed.
+func restoreOne(name string, entry *Entry, reg *registry.Registry, cfg *config.Config, opts RestoreOptions) (bool, error) {
+	platform := fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH)
+	if entry.Platform != "" && entry.Platform != platform {
+		return false, fmt.Errorf("locked for %s, but this is %s", entry.Platform, platform)
+	}
+
+	owner, repo, _, err := github.ParseSource(entry.Source)
+	if err != nil {
+		return false, err
+	}
+	if repo != name {
+		return false, fmt.Errorf("execman installs %s as %s, not %s", entry.Source, repo, name)
+	}
+
+	into := opts.Into
+	layout := ""
+	if existing, found := reg.Get(name); found {
+		existingOwner, existingRepo, _, err := github.ParseSource(existing.Source)
+		if err != nil || !strings.EqualFold(existingOwner+"/"+existingRepo, owner+"/"+repo) {
+			return false, fmt.Errorf("already managed from %s", existing.Source)
+		}
+		if existing.Version == entry.Version && existing.Checksum == entry.Checksum &&
+			archive.VerifyChecksum(existing.Path, entry.Checksum) == nil {
+			fmt.Println("Already matches the lockfile.")
+			return false, nil
+		}
+		into = filepath.Dir(existing.Path)
+		layout = existing.Layout
+		if layout == "" {
+			layout = registry.LayoutCopy
+		}
+	}
+	if into == "" {
+		into = cfg.DefaultInstallDir
+	}
+
+	err = install.Run(install.Options{
+		Source:                  github.ToURL(owner, repo) + "@" + entry.Version,
+		Into:                    into,
+		Yes:                     true,
+		Layout:                  layout,
+		AssetName:               entry.AssetName,
+		ExpectedArchiveChecksum: entry.ArchiveChecksum,
+		ExpectedChecksum:        entry.Checksum,
+	})
+	return err == nil, err
+}
@@ -0,0 +1,129 @@
+// Package lockfile records the exact release assets behind the managed
+// executables, so that other machines can install identical binaries.
+package lockfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/atomicfile"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/source"
+)
+
+// DefaultPath is the lockfile written and read when none is named.
+const DefaultPath = "execman-lock.json"
+
+// CurrentVersion is the lockfile format version written by this build.
+const CurrentVersion = 1
+
+// Lockfile pins each executable to one release asset.
+type Lockfile struct {
+	Version     int               `json:"lockfile_version"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Executables map[string]*Entry `json:"executables"`
+}
+
+// Entry pins one executable.
+type Entry struct {
+	Source          string `json:"source"`
+	Version         string `json:"version"`
+	Platform        string `json:"platform,omitempty"`
+	AssetName       string `json:"asset_name,omitempty"`
+	ArchiveChecksum string `json:"archive_checksum,omitempty"`
+	Checksum        string `json:"checksum"`
+}
+
+// FromRegistry builds a lockfile from the registry. Entries that cannot be
+// reproduced, such as adopted executables of unknown version, are left out
+// and returned by name with the reason.
+func FromRegistry(reg *registry.Registry) (*Lockfile, map[string]string) {
+	lf := &Lockfile{
+		Version:     CurrentVersion,
+		GeneratedAt: time.Now().UTC(),
+		Executables: make(map[string]*Entry),
+	}
+	skipped := make(map[string]string)
+
+	for _, name := range reg.List() {
+		exec, _ := reg.Get(name)
+		switch {
+		case exec.Version == "" || exec.Version == registry.UnknownVersion:
+			skipped[name] = "version unknown"
+			continue
+		case exec.Checksum == "":
+			skipped[name] = "no checksum recorded"
+			continue
+		case exec.URLSource != nil || source.HasProvider(exec.Source):
+			skipped[name] = "not a GitHub source"
+			continue
+		case exec.BuiltFrom != "":
+			skipped[name] = "built from source"
+			continue
+		}
+		lf.Executables[name] = &Entry{
+			Source:          exec.Source,
+			Version:         exec.Version,
+			Platform:        exec.Platform,
+			AssetName:       exec.AssetName,
+			ArchiveChecksum: exec.ArchiveChecksum,
+			Checksum:        exec.Checksum,
+		}
+	}
+	return lf, skipped
+}
+
+// Names returns the locked executable names in order.
+func (lf *Lockfile) Names() []string {
+	names := make([]string, 0, len(lf.Executables))
+	for name := range lf.Executables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save writes the lockfile to path.
+func (lf *Lockfile) Save(path string) error {
+	data, err := json.MarshalIndent(lf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lockfile: %w", err)
+	}
+	data = append(data, '\n')
+	// #nosec G306 -- The lockfile is meant to be checked in and shared
+	if err := atomicfile.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	return nil
+}
+
+// Load reads a lockfile.
+func Load(path string) (*Lockfile, error) {
+	// #nosec G304 -- Reading the lockfile named by the user
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+
+	var lf Lockfile
+	if err := json.Unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile: %w", err)
+	}
+	// Any JSON file parses, so one without a format version, such as a
+	// manifest, is not taken for an empty lockfile.
+	if lf.Version < 1 {
+		return nil, fmt.Errorf("%s is not an execman lockfile: no lockfile_version", path)
+	}
+	if lf.Version > CurrentVersion {
+		return nil, fmt.Errorf("lockfile version %d is newer than this execman supports (%d); upgrade execman", lf.Version, CurrentVersion)
+	}
+	for name, entry := range lf.Executables {
+		if entry == nil || entry.Source == "" || entry.Version == "" || entry.Checksum == "" {
+			return nil, fmt.Errorf("lockfile entry %s must have source, version and checksum", name)
+		}
+	}
+	return &lf, nil
+}
@@ -0,0 +1,73 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+func TestFromRegistryRoundTrip(t *testing.T) {
+	reg := &registry.Registry{Executables: map[string]*registry.Executable{
+		"pathman": {
+			Source:          "https://github.com/sfkleach/pathman",
+			Version:         "v0.3.0",
+			Platform:        "linux/amd64",
+			Checksum:        "sha256:aaaa",
+			AssetName:       "pathman_Linux_x86_64.tar.gz",
+			ArchiveChecksum: "sha256:bbbb",
+		},
+		"adopted": {Source: "https://github.com/acme/adopted", Version: registry.UnknownVersion, Checksum: "sha256:cccc"},
+	}}
+
+	lf, skipped := FromRegistry(reg)
+	if _, ok := skipped["adopted"]; !ok || len(skipped) != 1 {
+		t.Errorf("expected only adopted to be skipped, got %v", skipped)
+	}
+
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	if err := lf.Save(path); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if names := loaded.Names(); len(names) != 1 || names[0] != "pathman" {
+		t.Fatalf("expected only pathman to be locked, got %v", names)
+	}
+	entry := loaded.Executables["pathman"]
+	if entry.Version != "v0.3.0" || entry.AssetName != "pathman_Linux_x86_64.tar.gz" ||
+		entry.ArchiveChecksum != "sha256:bbbb" || entry.Checksum != "sha256:aaaa" {
+		t.Errorf("unexpected locked entry %+v", entry)
+	}
+}
+
+func TestLoadRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		lockfile string
+		wantErr  string
+	}{
+		{name: "newer version", lockfile: `{"lockfile_version": 99, "executables": {}}`, wantErr: "newer"},
+		{name: "no version", lockfile: `{"executables": {}}`, wantErr: "not an execman lockfile"},
+		{name: "manifest", lockfile: `{"tools": [{"source": "github.com/a/tool"}]}`, wantErr: "not an execman lockfile"},
+		{name: "missing checksum", lockfile: `{"lockfile_version": 1, "executables": {"tool": {"source": "github.com/a/tool", "version": "v1"}}}`, wantErr: "checksum"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), DefaultPath)
+			if err := os.WriteFile(path, []byte(tt.lockfile), 0600); err != nil {
+				t.Fatalf("failed to write lockfile: %v", err)
+			}
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Package manifest reads the declarative list of tools that execman sync
+// installs.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/semver"
+)
+
+// DefaultPath is the manifest read when none is named.
+const DefaultPath = "execman.json"
+
+// Manifest lists the tools that should be installed.
+type Manifest struct {
+	// InstallDir is where tools are installed unless a tool says otherwise.
+	// Empty means the configured default install directory.
+	InstallDir         string `json:"install_dir,omitempty"`
+	IncludePrereleases bool   `json:"include_prereleases,omitempty"`
+	Tools              []Tool `json:"tools"`
+}
+
+// Tool is one entry in the manifest.
+type Tool struct {
+	Source string `json:"source"`
+	// Version is an exact release tag, a semver constraint such as ^1.2 or
+	// ">=1.4, <2", or empty (or "latest") for the latest release.
+	Version    string `json:"version,omitempty"`
+	InstallDir string `json:"install_dir,omitempty"`
+
+	owner, repo string
+	constraint  *semver.Constraint // Nil for an exact tag.
+}
+
+// Load reads and validates a manifest.
+func Load(path string) (*Manifest, error) {
+	// #nosec G304 -- Reading the manifest named by the user
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates manifest JSON.
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	var err error
+	if m.InstallDir, err = expandHome(m.InstallDir); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for i := range m.Tools {
+		tool := &m.Tools[i]
+		owner, repo, version, err := github.ParseSource(tool.Source)
+		if err != nil {
+			return nil, fmt.Errorf("tool %d: %w", i+1, err)
+		}
+		if version != "" {
+			if tool.Version != "" && tool.Version != version {
+				return nil, fmt.Errorf("tool %s: version given both in source (%s) and version (%s)", repo, version, tool.Version)
+			}
+			tool.Version = version
+		}
+		tool.owner, tool.repo = owner, repo
+		if names[repo] {
+			return nil, fmt.Errorf("tool %s is listed more than once", repo)
+		}
+		names[repo] = true
+
+		if tool.constraint, err = parseVersion(tool.Version); err != nil {
+			return nil, fmt.Errorf("tool %s: %w", repo, err)
+		}
+		if tool.InstallDir, err = expandHome(tool.InstallDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return &m, nil
+}
+
+// parseVersion returns the constraint a version field stands for, or nil if
+// it names one exact release tag. Anything with an operator is a constraint,
+// as is a partial version such as 1.2; a complete version such as v1.2.3, or
+// a tag that is not semver at all, is used exactly as written.
+func parseVersion(version string) (*semver.Constraint, error) {
+	if version == "latest" {
+		version = ""
+	}
+	if version == "" || strings.ContainsAny(version, "<>=!^~*, ") {
+		return semver.ParseConstraint(version)
+	}
+	if strings.Count(version, ".") < 2 {
+		if _, err := semver.Parse(version); err == nil {
+			return semver.ParseConstraint(version)
+		}
+	}
+	return nil, nil
+}
+
+// expandHome replaces a leading ~ with the user's home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// Name returns the name the tool is installed under.
+func (t *Tool) Name() string {
+	return t.repo
+}
+
+// Owner returns the GitHub owner of the tool's repository.
+func (t *Tool) Owner() string {
+	return t.owner
+}
+
+// URL returns the tool's repository URL, as recorded in the registry.
+func (t *Tool) URL() string {
+	return github.ToURL(t.owner, t.repo)
+}
+
+// Exact reports whether the tool's version names a single release tag.
+func (t *Tool) Exact() bool {
+	return t.constraint == nil
+}
+
+// Constraint returns the tool's version constraint; it is nil when the
+// version is an exact tag.
+func (t *Tool) Constraint() *semver.Constraint {
+	return t.constraint
+}
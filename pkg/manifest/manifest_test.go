@@ -0,0 +1,70 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	m, err := Parse([]byte(`{
+		"install_dir": "/opt/tools/bin",
+		"tools": [
+			{"source": "github.com/sfkleach/pathman", "version": "^0.3"},
+			{"source": "https://github.com/cli/cli", "version": "v2.40.0"},
+			{"source": "github.com/acme/nightly", "version": "nightly-2026-01-01"},
+			{"source": "github.com/acme/tool@v1.0.0"},
+			{"source": "github.com/acme/latest", "install_dir": "/usr/local/bin"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		exact   bool
+	}{
+		{name: "pathman", version: "^0.3"},
+		{name: "cli", version: "v2.40.0", exact: true},
+		{name: "nightly", version: "nightly-2026-01-01", exact: true},
+		{name: "tool", version: "v1.0.0", exact: true},
+		{name: "latest", version: ""},
+	}
+	if len(m.Tools) != len(tests) {
+		t.Fatalf("expected %d tools, got %d", len(tests), len(m.Tools))
+	}
+	for i, tt := range tests {
+		tool := &m.Tools[i]
+		if tool.Name() != tt.name || tool.Version != tt.version || tool.Exact() != tt.exact {
+			t.Errorf("tool %d: expected %s %q (exact %v), got %s %q (exact %v)",
+				i, tt.name, tt.version, tt.exact, tool.Name(), tool.Version, tool.Exact())
+		}
+	}
+	if m.Tools[4].InstallDir != "/usr/local/bin" {
+		t.Errorf("expected per-tool install dir, got %q", m.Tools[4].InstallDir)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+	}{
+		{name: "bad json", manifest: `{"tools": [`, wantErr: "parse"},
+		{name: "bad source", manifest: `{"tools": [{"source": "pathman"}]}`, wantErr: "invalid GitHub source"},
+		{name: "duplicate", manifest: `{"tools": [{"source": "a/tool"}, {"source": "b/tool"}]}`, wantErr: "more than once"},
+		{name: "bad constraint", manifest: `{"tools": [{"source": "a/tool", "version": ">=one"}]}`, wantErr: "invalid constraint"},
+		{name: "two versions", manifest: `{"tools": [{"source": "a/tool@v1.0.0", "version": "v2.0.0"}]}`, wantErr: "both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.manifest))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package mirror
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/httpclient"
+	"github.com/spf13/cobra"
+)
+
+// ServeOptions for the serve-mirror command.
+type ServeOptions struct {
+	Listen     string
+	CacheDir   string // Empty means the user's cache directory.
+	AllowHosts []string
+	TTL        time.Duration
+}
+
+// NewServeMirrorCommand creates the serve-mirror command.
+func NewServeMirrorCommand() *cobra.Command {
+	var opts ServeOptions
+
+	cmd := &cobra.Command{
+		Use:   "serve-mirror",
+		Short: "Run a caching mirror of release metadata and assets",
+		Long: `Serve a read-through caching proxy for GitHub release metadata and assets, so that
+hosts on a local network fetch each release once between them. A request for
+/<host>/<path> is answered from the cache, or fetched from https://<host>/<path> and
+cached. Cached responses are checked with upstream again after --ttl, and served as
+they are if upstream cannot be reached.
+
+Point execman at the mirror with a "mirrors" entry in its config, such as
+  "mirrors": {"*": "http://mirror.local:8080/{host}/{path}"}`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Serve(opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.Listen, "listen", "127.0.0.1:8080", "Address to listen on; use :8080 to serve the local network")
+	cmd.Flags().StringVar(&opts.CacheDir, "cache-dir", "", "Directory for cached responses (default execman/mirror in the user cache directory)")
+	cmd.Flags().StringSliceVar(&opts.AllowHosts, "allow-host", nil, "Also mirror this upstream host, such as a download server; may be repeated")
+	cmd.Flags().DurationVar(&opts.TTL, "ttl", 10*time.Minute, "How long to serve a cached response before checking upstream again")
+
+	return cmd
+}
+
+// Serve executes the serve-mirror command.
+func Serve(opts ServeOptions) error {
+	if opts.CacheDir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return fmt.Errorf("failed to find cache directory: %w", err)
+		}
+		opts.CacheDir = filepath.Join(cacheDir, "execman", "mirror")
+	}
+	if err := os.MkdirAll(opts.CacheDir, 0750); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Upstream requests get the configured network settings, but never the
+	// configured mirrors, which may well point back at this one.
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	client, err := httpclient.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	hosts := append([]string(nil), DefaultHosts...)
+	for _, host := range opts.AllowHosts {
+		if host == "" || strings.ContainsAny(host, "/?#@") {
+			return fmt.Errorf("invalid host %q", host)
+		}
+		hosts = append(hosts, strings.ToLower(host))
+	}
+
+	server := &http.Server{
+		Addr: opts.Listen,
+		Handler: &Server{
+			CacheDir: opts.CacheDir,
+			Hosts:    hosts,
+			TTL:      opts.TTL,
+			Client:   client,
+		},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Printf("Mirroring %s on %s, caching in %s\n", strings.Join(hosts, ", "), opts.Listen, opts.CacheDir)
+	return server.ListenAndServe()
+}
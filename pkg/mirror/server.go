@@ -0,0 +1,257 @@
+// Package mirror serves a read-through caching mirror of release metadata
+// and assets, which execman on other hosts can be pointed at with the
+// mirrors config.
+package mirror
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/atomicfile"
+)
+
+// DefaultHosts are the upstream hosts a mirror serves unless told otherwise:
+// the GitHub API and the hosts GitHub serves release assets from.
+var DefaultHosts = []string{
+	"api.github.com",
+	"github.com",
+	"objects.githubusercontent.com",
+	"release-assets.githubusercontent.com",
+}
+
+// Server is a read-through caching proxy for release metadata and assets. A
+// request for /<host>/<path> is answered from the cache when the cached copy
+// is fresh, and otherwise fetched from https://<host>/<path> and cached.
+// Upstream redirects are followed, so what is cached is the content itself.
+type Server struct {
+	CacheDir string
+	Hosts    []string      // Upstream hosts that may be requested.
+	TTL      time.Duration // How long a cached response is served without checking upstream.
+	Client   *http.Client  // Nil means http.DefaultClient.
+	// Scheme is how upstream hosts are reached. Empty means https.
+	Scheme string
+}
+
+// entry is the metadata stored beside each cached response body.
+type entry struct {
+	URL          string    `json:"url"`
+	ContentType  string    `json:"content_type,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	upstream, err := s.upstreamURL(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	accept := r.Header.Get("Accept")
+	key := cacheKey(upstream, accept)
+	cached, found := s.load(key)
+	if found && time.Since(cached.FetchedAt) < s.TTL {
+		fmt.Printf("hit    %s\n", upstream)
+		s.serveCached(w, key, cached, "hit")
+		return
+	}
+
+	status, err := s.fetch(upstream, accept, key, cached)
+	if err == nil && status >= http.StatusInternalServerError {
+		err = fmt.Errorf("upstream status %d", status)
+	}
+	switch {
+	case err != nil && found:
+		// An unavailable upstream should not stop hosts installing what
+		// the mirror already has.
+		fmt.Printf("Warning: serving stale %s: %v\n", upstream, err)
+		s.serveCached(w, key, cached, "stale")
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	case status != http.StatusOK:
+		// Errors such as 404 are passed on but not cached.
+		http.Error(w, http.StatusText(status), status)
+	default:
+		fmt.Printf("fetch  %s\n", upstream)
+		refreshed, ok := s.load(key)
+		if !ok {
+			http.Error(w, "cached response is unavailable", http.StatusInternalServerError)
+			return
+		}
+		s.serveCached(w, key, refreshed, "miss")
+	}
+}
+
+// upstreamURL maps a mirror request path to the upstream URL it stands for.
+func (s *Server) upstreamURL(u *url.URL) (string, error) {
+	host, rest, _ := strings.Cut(strings.TrimPrefix(u.EscapedPath(), "/"), "/")
+	host = strings.ToLower(host)
+	allowed := false
+	for _, h := range s.Hosts {
+		if strings.EqualFold(h, host) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("host %q is not mirrored", host)
+	}
+
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	target, err := url.Parse(scheme + "://" + host + "/" + rest)
+	if err != nil || target.Host != host {
+		return "", fmt.Errorf("invalid mirror path %s", u.Path)
+	}
+	target.RawQuery = u.RawQuery
+	return target.String(), nil
+}
+
+// cacheKey names the cache files for a response. Accept is part of the key
+// because the GitHub API answers differently depending on it.
+func cacheKey(upstream, accept string) string {
+	sum := sha256.Sum256([]byte(upstream + "\n" + accept))
+	return hex.EncodeToString(sum[:])
+}
+
+// paths returns the body and metadata file paths for a cache key.
+func (s *Server) paths(key string) (body, meta string) {
+	dir := filepath.Join(s.CacheDir, key[:2])
+	return filepath.Join(dir, key), filepath.Join(dir, key+".json")
+}
+
+// load reads a cached response's metadata.
+func (s *Server) load(key string) (*entry, bool) {
+	body, meta := s.paths(key)
+	// #nosec G304 -- The path is derived from a hash inside the cache directory
+	data, err := os.ReadFile(meta)
+	if err != nil {
+		return nil, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, false
+	}
+	if _, err := os.Stat(body); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+// fetch requests upstream, revalidating cached if there is one, and stores a
+// successful response in the cache. It returns the upstream status.
+func (s *Server) fetch(upstream, accept, key string, cached *entry) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, upstream, nil)
+	if err != nil {
+		return 0, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch %s: %w", upstream, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		cached.FetchedAt = time.Now()
+		return http.StatusOK, s.saveMeta(key, cached)
+	case resp.StatusCode != http.StatusOK:
+		return resp.StatusCode, nil
+	}
+
+	bodyPath, _ := s.paths(key)
+	if err := os.MkdirAll(filepath.Dir(bodyPath), 0750); err != nil {
+		return 0, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(bodyPath), key+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		_ = tmp.Close()
+		return 0, fmt.Errorf("failed to fetch %s: %w", upstream, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), bodyPath); err != nil {
+		return 0, fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	return http.StatusOK, s.saveMeta(key, &entry{
+		URL:          upstream,
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	})
+}
+
+// saveMeta writes a cached response's metadata.
+func (s *Server) saveMeta(key string, e *entry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	_, meta := s.paths(key)
+	if err := atomicfile.WriteFile(meta, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// serveCached writes a cached response to w.
+func (s *Server) serveCached(w http.ResponseWriter, key string, e *entry, state string) {
+	bodyPath, _ := s.paths(key)
+	// #nosec G304 -- The path is derived from a hash inside the cache directory
+	f, err := os.Open(bodyPath)
+	if err != nil {
+		http.Error(w, "cached response is unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	if e.ContentType != "" {
+		w.Header().Set("Content-Type", e.ContentType)
+	}
+	w.Header().Set("X-Execman-Mirror", state)
+	if info, err := f.Stat(); err == nil {
+		w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	}
+	_, _ = io.Copy(w, f)
+}
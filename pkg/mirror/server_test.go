@@ -0,0 +1,149 @@
+package mirror
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/httpclient"
+)
+
+// newUpstream returns a server standing in for an upstream host. It counts
+// the requests that reach it and supports revalidation with an ETag.
+func newUpstream(t *testing.T, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/repos/o/r/releases":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[{"tag_name": "v1.0.0"}]`))
+		case "/o/r/releases/download/v1.0.0/r.tar.gz":
+			http.Redirect(w, r, "/assets/r.tar.gz", http.StatusFound)
+		case "/assets/r.tar.gz":
+			_, _ = w.Write([]byte("archive"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get fetches url with client and returns the body and mirror state.
+func get(t *testing.T, client *http.Client, url string) (int, string, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s returned error: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", url, err)
+	}
+	return resp.StatusCode, string(body), resp.Header.Get("X-Execman-Mirror")
+}
+
+func TestServer(t *testing.T) {
+	var hits atomic.Int32
+	upstream := newUpstream(t, &hits)
+	upstreamHost := strings.TrimPrefix(upstream.URL, "http://")
+
+	srv := &Server{CacheDir: t.TempDir(), Hosts: []string{upstreamHost}, TTL: time.Hour, Scheme: "http"}
+	mirrorServer := httptest.NewServer(srv)
+	defer mirrorServer.Close()
+	base := mirrorServer.URL + "/" + upstreamHost
+
+	status, body, state := get(t, http.DefaultClient, base+"/repos/o/r/releases")
+	if status != http.StatusOK || body != `[{"tag_name": "v1.0.0"}]` || state != "miss" {
+		t.Fatalf("first request = %d %q (%s)", status, body, state)
+	}
+	_, body, state = get(t, http.DefaultClient, base+"/repos/o/r/releases")
+	if body != `[{"tag_name": "v1.0.0"}]` || state != "hit" || hits.Load() != 1 {
+		t.Errorf("second request = %q (%s) after %d upstream requests, want a cache hit", body, state, hits.Load())
+	}
+
+	// Redirects are followed and the content cached under the original URL.
+	_, body, _ = get(t, http.DefaultClient, base+"/o/r/releases/download/v1.0.0/r.tar.gz")
+	if body != "archive" {
+		t.Errorf("asset = %q, want the redirect target's content", body)
+	}
+
+	// Stale entries are revalidated, and served as they are when upstream
+	// is down.
+	srv.TTL = 0
+	before := hits.Load()
+	_, body, _ = get(t, http.DefaultClient, base+"/repos/o/r/releases")
+	if body != `[{"tag_name": "v1.0.0"}]` || hits.Load() != before+1 {
+		t.Errorf("revalidated request = %q after %d upstream requests", body, hits.Load()-before)
+	}
+	upstream.Close()
+	_, body, state = get(t, http.DefaultClient, base+"/repos/o/r/releases")
+	if body != `[{"tag_name": "v1.0.0"}]` || state != "stale" {
+		t.Errorf("request with upstream down = %q (%s), want the stale copy", body, state)
+	}
+
+	if status, _, _ := get(t, http.DefaultClient, mirrorServer.URL+"/example.com/anything"); status != http.StatusNotFound {
+		t.Errorf("request for an unmirrored host returned %d, want 404", status)
+	}
+}
+
+func TestServerPassesErrorsWithoutCaching(t *testing.T) {
+	var hits atomic.Int32
+	upstream := newUpstream(t, &hits)
+	upstreamHost := strings.TrimPrefix(upstream.URL, "http://")
+
+	mirrorServer := httptest.NewServer(&Server{CacheDir: t.TempDir(), Hosts: []string{upstreamHost}, TTL: time.Hour, Scheme: "http"})
+	defer mirrorServer.Close()
+
+	for i := 0; i < 2; i++ {
+		if status, _, _ := get(t, http.DefaultClient, mirrorServer.URL+"/"+upstreamHost+"/missing"); status != http.StatusNotFound {
+			t.Fatalf("request for a missing file returned %d, want 404", status)
+		}
+	}
+	if hits.Load() != 2 {
+		t.Errorf("upstream saw %d requests, want 2", hits.Load())
+	}
+}
+
+func TestTransportThroughMirror(t *testing.T) {
+	var hits atomic.Int32
+	upstream := newUpstream(t, &hits)
+	upstreamHost := strings.TrimPrefix(upstream.URL, "http://")
+
+	mirrorServer := httptest.NewServer(&Server{CacheDir: t.TempDir(), Hosts: []string{upstreamHost}, TTL: time.Hour, Scheme: "http"})
+	defer mirrorServer.Close()
+
+	rules, err := httpclient.NewMirrorRules(map[string]string{upstreamHost: mirrorServer.URL + "/{host}/{path}"})
+	if err != nil {
+		t.Fatalf("NewMirrorRules returned error: %v", err)
+	}
+	client := &http.Client{Transport: &httpclient.MirrorTransport{Rules: rules}}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(upstream.URL + "/repos/o/r/releases")
+		if err != nil {
+			t.Fatalf("GET returned error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.Header.Get("X-Execman-Mirror") == "" {
+			t.Error("response did not come from the mirror")
+		}
+		if got := resp.Request.URL.String(); got != upstream.URL+"/repos/o/r/releases" {
+			t.Errorf("response request URL = %s, want the upstream URL", got)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("upstream saw %d requests, want 1", hits.Load())
+	}
+}
@@ -0,0 +1,203 @@
+// Package policy enforces which sources execman is allowed to install from.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// SystemPolicyPath is the location of the system-wide policy file. It is a
+// variable so that tests can point it somewhere harmless.
+var SystemPolicyPath = "/etc/execman/policy.json"
+
+// File is the on-disk format of a policy file.
+type File struct {
+	// Allow lists the sources that may be used. When empty, every source not
+	// denied is allowed.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists sources that may never be used. Deny rules take precedence
+	// over allow rules.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// ruleSet is a policy file together with where it was loaded from, so that
+// errors can cite the file responsible.
+type ruleSet struct {
+	File
+	path string
+}
+
+// Policy is the combination of the system-wide and per-user policy files.
+type Policy struct {
+	sets []ruleSet
+}
+
+// BlockedError reports that a source was rejected by a policy rule.
+type BlockedError struct {
+	Source string
+	Rule   string // Empty when the source matched no allow rule.
+	Path   string
+}
+
+func (e *BlockedError) Error() string {
+	if e.Rule == "" {
+		return fmt.Sprintf("source %s is blocked by policy: not matched by any allow rule in %s", e.Source, e.Path)
+	}
+	return fmt.Sprintf("source %s is blocked by policy: deny rule %q in %s", e.Source, e.Rule, e.Path)
+}
+
+// DefaultUserPolicyPath returns the per-user policy file path.
+func DefaultUserPolicyPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user config directory: %w", err)
+	}
+	return filepath.Join(configDir, "execman", "policy.json"), nil
+}
+
+// Load loads the system-wide and per-user policy files. Missing files are
+// treated as empty policies.
+func Load() (*Policy, error) {
+	userPath, err := DefaultUserPolicyPath()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFrom(SystemPolicyPath, userPath)
+}
+
+// LoadFrom loads and combines the policy files at the given paths.
+func LoadFrom(paths ...string) (*Policy, error) {
+	p := &Policy{}
+	for _, policyPath := range paths {
+		// #nosec G304 -- Reading policy from trusted system and user paths
+		data, err := os.ReadFile(policyPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read policy %s: %w", policyPath, err)
+		}
+
+		var f File
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to parse policy %s: %w", policyPath, err)
+		}
+		for _, rule := range append(append([]string{}, f.Allow...), f.Deny...) {
+			if _, err := path.Match(normalize(rule), ""); err != nil {
+				return nil, fmt.Errorf("invalid rule %q in policy %s: %w", rule, policyPath, err)
+			}
+		}
+		p.sets = append(p.sets, ruleSet{File: f, path: policyPath})
+	}
+	return p, nil
+}
+
+// Check returns a *BlockedError if the source is not permitted. Each policy
+// file is applied independently, so a per-user file can narrow but never
+// widen what the system-wide file allows.
+func (p *Policy) Check(source string) error {
+	subject := normalize(source)
+	for _, set := range p.sets {
+		for _, rule := range set.Deny {
+			if matches(rule, subject) {
+				return &BlockedError{Source: source, Rule: rule, Path: set.path}
+			}
+		}
+		if len(set.Allow) == 0 {
+			continue
+		}
+		allowed := false
+		for _, rule := range set.Allow {
+			if matches(rule, subject) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &BlockedError{Source: source, Path: set.path}
+		}
+	}
+	return nil
+}
+
+// Enforce loads the policy and checks the source against it.
+func Enforce(source string) error {
+	p, err := Load()
+	if err != nil {
+		return err
+	}
+	return p.Check(source)
+}
+
+// matches reports whether a rule matches a normalized source or a path the
+// source lies beneath. A rule naming only an owner therefore matches every
+// repository belonging to that owner, and one naming a download host or
+// directory matches every URL under it.
+func matches(rule, subject string) bool {
+	pattern := normalize(rule)
+	for {
+		if ok, _ := path.Match(pattern, subject); ok {
+			return true
+		}
+		i := strings.LastIndex(subject, "/")
+		if i < 0 {
+			return false
+		}
+		subject = subject[:i]
+	}
+}
+
+// normalize reduces a source or rule to a lowercase host/owner/repo form so
+// that "owner/repo", "github.com/owner/repo" and "https://github.com/owner/repo"
+// are all equivalent. A version suffix is discarded.
+func normalize(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+	if i := strings.Index(s, "@"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSuffix(s, "/")
+	s = strings.TrimSuffix(s, ".git")
+
+	// Rules and sources without an explicit host refer to GitHub.
+	first, _, _ := strings.Cut(s, "/")
+	if !strings.Contains(first, ".") && !strings.Contains(first, ":") {
+		s = "github.com/" + s
+	}
+	return s
+}
+
+// NewPolicyCommand creates the policy command.
+func NewPolicyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Inspect the source allowlist and denylist policy",
+		Long: `Inspect the policy that restricts which sources execman may install from.
+Policies are read from ` + SystemPolicyPath + ` and the per-user policy.json.`,
+	}
+
+	checkCmd := &cobra.Command{
+		Use:   "check <source>",
+		Short: "Check whether a source is permitted by policy",
+		Long:  "Check whether a source is permitted by policy. Exits with a non-zero status if it is blocked.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := Enforce(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("%s is allowed by policy.\n", args[0])
+			return nil
+		},
+	}
+	cmd.AddCommand(checkCmd)
+
+	return cmd
+}
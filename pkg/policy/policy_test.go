@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePolicy(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	policyPath := filepath.Join(dir, name)
+	if err := os.WriteFile(policyPath, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+	return policyPath
+}
+
+func TestCheckNoPolicyAllowsEverything(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	p, err := LoadFrom(filepath.Join(tmpDir, "missing.json"))
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if err := p.Check("github.com/anyone/anything"); err != nil {
+		t.Errorf("expected source to be allowed, got %v", err)
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	policyPath := writePolicy(t, tmpDir, "policy.json", `{
+		"allow": ["sfkleach", "cli/cli", "acme/tool-*", "releases.example.com/tools"],
+		"deny": ["sfkleach/legacy", "releases.example.com/tools/old"]
+	}`)
+
+	p, err := LoadFrom(policyPath)
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+
+	tests := []struct {
+		source   string
+		wantRule string
+		blocked  bool
+	}{
+		{source: "github.com/sfkleach/execman"},
+		{source: "https://github.com/sfkleach/pathman"},
+		{source: "sfkleach/pathman@v1.0.0"},
+		{source: "github.com/CLI/CLI"},
+		{source: "github.com/acme/tool-lint"},
+		{source: "github.com/sfkleach/legacy", blocked: true, wantRule: "sfkleach/legacy"},
+		{source: "github.com/acme/other", blocked: true},
+		{source: "github.com/cli/cli-extra", blocked: true},
+		{source: "https://releases.example.com/tools/tool/{version}/tool_{os}_{arch}.zip"},
+		{source: "https://releases.example.com/tools/old/{version}/old.zip", blocked: true, wantRule: "releases.example.com/tools/old"},
+		{source: "https://releases.example.com/other/{version}/tool.zip", blocked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			err := p.Check(tt.source)
+			if !tt.blocked {
+				if err != nil {
+					t.Errorf("expected %s to be allowed, got %v", tt.source, err)
+				}
+				return
+			}
+
+			var blocked *BlockedError
+			if !errors.As(err, &blocked) {
+				t.Fatalf("expected BlockedError for %s, got %v", tt.source, err)
+			}
+			if blocked.Rule != tt.wantRule {
+				t.Errorf("expected rule %q, got %q", tt.wantRule, blocked.Rule)
+			}
+			if !strings.Contains(err.Error(), policyPath) {
+				t.Errorf("expected error to cite %s, got %q", policyPath, err.Error())
+			}
+		})
+	}
+}
+
+func TestUserPolicyCannotWidenSystemPolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemPath := writePolicy(t, tmpDir, "system.json", `{"allow": ["sfkleach/*"]}`)
+	userPath := writePolicy(t, tmpDir, "user.json", `{"allow": ["evil/*"], "deny": ["sfkleach/pathman"]}`)
+
+	p, err := LoadFrom(systemPath, userPath)
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+
+	var blocked *BlockedError
+	if err := p.Check("github.com/evil/tool"); !errors.As(err, &blocked) || blocked.Path != systemPath {
+		t.Errorf("expected system policy to block evil/tool, got %v", err)
+	}
+	if err := p.Check("github.com/sfkleach/pathman"); !errors.As(err, &blocked) || blocked.Path != userPath {
+		t.Errorf("expected user policy to block sfkleach/pathman, got %v", err)
+	}
+}
+
+func TestLoadFromRejectsBadPattern(t *testing.T) {
+	tmpDir := t.TempDir()
+	policyPath := writePolicy(t, tmpDir, "policy.json", `{"deny": ["owner/[repo"]}`)
+
+	if _, err := LoadFrom(policyPath); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNewerSchema is returned when saving a registry whose schema is newer
+// than this build understands. Writing it would silently drop the fields
+// this build does not know about.
+var ErrNewerSchema = errors.New("registry was written by a newer version of execman")
+
+// migration upgrades a raw registry document by exactly one schema version.
+// Migrations work on the generic JSON form, so that each one only needs to
+// know about the two schemas it connects rather than the current structs.
+type migration func(doc map[string]any) error
+
+// migrations maps each schema version to the migration that upgrades it to
+// the next version. Every version below CurrentSchemaVersion must have one.
+var migrations = map[int]migration{
+	1: migrateV1ToV2,
+	2: migrateV2ToV3,
+	3: migrateV3ToV4,
+	4: migrateV4ToV5,
+	5: migrateV5ToV6,
+	6: migrateV6ToV7,
+}
+
+// migrateV1ToV2 introduces the asset provenance fields (asset_name,
+// asset_url, archive_checksum, checksum_source and downloaded_at). They
+// cannot be recovered for existing entries, so they are left absent and
+// filled in by the next install or update.
+func migrateV1ToV2(doc map[string]any) error {
+	return nil
+}
+
+// migrateV2ToV3 introduces the per-executable version history. Existing
+// entries start with an empty history.
+func migrateV2ToV3(doc map[string]any) error {
+	return nil
+}
+
+// migrateV3ToV4 introduces the install layout. Existing entries were all
+// installed as copies, which an absent layout means.
+func migrateV3ToV4(doc map[string]any) error {
+	return nil
+}
+
+// migrateV4ToV5 introduces the remembered symlink policy. Existing entries
+// have none, so update asks again or uses the configured default.
+func migrateV4ToV5(doc map[string]any) error {
+	return nil
+}
+
+// migrateV5ToV6 introduces download URL template sources. Existing entries
+// all come from GitHub, which an absent url_source means.
+func migrateV5ToV6(doc map[string]any) error {
+	return nil
+}
+
+// migrateV6ToV7 introduces the marker for binaries built from source.
+// Existing entries were all extracted from release assets, which an absent
+// built_from means.
+func migrateV6ToV7(doc map[string]any) error {
+	return nil
+}
+
+// decode parses registry JSON, upgrading older schemas step by step to
+// CurrentSchemaVersion. A registry with a newer schema is decoded as-is and
+// keeps its version, which prevents it from being saved.
+func decode(data []byte) (*Registry, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	version, err := schemaVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if version < CurrentSchemaVersion {
+		for v := version; v < CurrentSchemaVersion; v++ {
+			migrate, ok := migrations[v]
+			if !ok {
+				return nil, fmt.Errorf("no migration from registry schema version %d", v)
+			}
+			if err := migrate(doc); err != nil {
+				return nil, fmt.Errorf("failed to migrate registry from schema version %d: %w", v, err)
+			}
+			doc["schema_version"] = v + 1
+		}
+
+		migrated, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-encode migrated registry: %w", err)
+		}
+		data = migrated
+	}
+
+	var reg Registry
+	if err := json.Unmarshal(data, &reg); err != nil {
+		return nil, err
+	}
+	return &reg, nil
+}
+
+// schemaVersion returns the schema version of a raw registry document.
+// Registries written before the version was recorded are schema 1.
+func schemaVersion(doc map[string]any) (int, error) {
+	raw, ok := doc["schema_version"]
+	if !ok {
+		return 1, nil
+	}
+	number, ok := raw.(float64)
+	if !ok || number < 1 || number != float64(int(number)) {
+		return 0, fmt.Errorf("invalid schema_version %v", raw)
+	}
+	return int(number), nil
+}
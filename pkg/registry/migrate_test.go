@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the migration golden files")
+
+// TestMigrationsGolden loads every registry in testdata/migrations and
+// compares the result, as Save would write it, with the matching
+// .golden.json file. Run with -update to regenerate the golden files after
+// adding a migration, then review the diff.
+func TestMigrationsGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "migrations", "*.json"))
+	if err != nil {
+		t.Fatalf("failed to list test data: %v", err)
+	}
+
+	tested := 0
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".golden.json") {
+			continue
+		}
+		tested++
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			reg, err := LoadFrom(input)
+			if err != nil {
+				t.Fatalf("LoadFrom returned error: %v", err)
+			}
+			if reg.SchemaVersion != CurrentSchemaVersion {
+				t.Errorf("expected schema version %d, got %d", CurrentSchemaVersion, reg.SchemaVersion)
+			}
+
+			got, err := json.MarshalIndent(reg, "", "  ")
+			if err != nil {
+				t.Fatalf("failed to marshal registry: %v", err)
+			}
+			got = append(got, '\n')
+
+			goldenPath := strings.TrimSuffix(input, ".json") + ".golden.json"
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, got, 0600); err != nil {
+					t.Fatalf("failed to write golden file: %v", err)
+				}
+			}
+			// #nosec G304 -- Reading test data
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("migrated registry does not match %s:\n%s", goldenPath, got)
+			}
+		})
+	}
+	if tested == 0 {
+		t.Fatal("no migration test data found")
+	}
+}
+
+func TestEveryOlderSchemaHasMigration(t *testing.T) {
+	for v := 1; v < CurrentSchemaVersion; v++ {
+		if _, ok := migrations[v]; !ok {
+			t.Errorf("no migration from schema version %d", v)
+		}
+	}
+}
+
+func TestNewerSchemaIsReadOnly(t *testing.T) {
+	registryPath := filepath.Join(t.TempDir(), "registry.json")
+	newer := `{
+  "schema_version": 99,
+  "executables": {
+    "tool": {"source": "https://github.com/owner/tool", "version": "v1.0.0", "future_field": true}
+  }
+}`
+	if err := os.WriteFile(registryPath, []byte(newer), 0600); err != nil {
+		t.Fatalf("failed to write registry: %v", err)
+	}
+
+	reg, err := LoadFrom(registryPath)
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if _, ok := reg.Get("tool"); !ok {
+		t.Error("expected entries of a newer registry to be readable")
+	}
+
+	if err := reg.Save(); !errors.Is(err, ErrNewerSchema) {
+		t.Fatalf("expected ErrNewerSchema, got %v", err)
+	}
+	err = UpdateAt(registryPath, func(r *Registry) error {
+		r.Remove("tool")
+		return nil
+	})
+	if !errors.Is(err, ErrNewerSchema) {
+		t.Fatalf("expected ErrNewerSchema from UpdateAt, got %v", err)
+	}
+
+	// #nosec G304 -- Reading file created by the test
+	data, err := os.ReadFile(registryPath)
+	if err != nil {
+		t.Fatalf("failed to read registry: %v", err)
+	}
+	if string(data) != newer {
+		t.Error("expected newer registry to be left untouched")
+	}
+}
+
+func TestDecodeRejectsInvalidSchemaVersion(t *testing.T) {
+	for _, doc := range []string{`{"schema_version": "two"}`, `{"schema_version": 0}`, `{"schema_version": 1.5}`} {
+		if _, err := decode([]byte(doc)); err == nil {
+			t.Errorf("expected error for %s", doc)
+		}
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/sfkleach/execman/pkg/atomicfile"
+	"github.com/sfkleach/execman/pkg/lock"
 )
 
 // Executable represents a managed executable in the registry.
@@ -16,8 +19,142 @@ type Executable struct {
 	Path        string    `json:"path"`
 	Platform    string    `json:"platform"`
 	Checksum    string    `json:"checksum"`
+	// RepoID is GitHub's numeric repository ID, which survives renames and
+	// transfers and so identifies the upstream repository unambiguously.
+	RepoID int64 `json:"repo_id,omitempty"`
+
+	// Provenance of the release asset the binary was extracted from (schema 2).
+	AssetName       string    `json:"asset_name,omitempty"`
+	AssetURL        string    `json:"asset_url,omitempty"`
+	ArchiveChecksum string    `json:"archive_checksum,omitempty"`
+	ChecksumSource  string    `json:"checksum_source,omitempty"` // URL of the checksums file that verified the asset.
+	DownloadedAt    time.Time `json:"downloaded_at,omitzero"`
+
+	// Hook is the outcome of the pre-activation hook for the current binary,
+	// if one was configured when it was installed.
+	Hook *HookResult `json:"pre_activation_hook,omitempty"`
+
+	// Layout is how the executable is installed (schema 4). Empty means
+	// LayoutCopy.
+	Layout string `json:"layout,omitempty"`
+
+	// SymlinkPolicy is how update handles Path being a symlink, as chosen
+	// interactively the first time (schema 5): "target", "link" or "skip".
+	SymlinkPolicy string `json:"symlink_policy,omitempty"`
+
+	// URLSource describes how to find releases of an executable published
+	// on its own download server rather than on GitHub (schema 6). Source is
+	// then the download URL template.
+	URLSource *URLSource `json:"url_source,omitempty"`
+
+	// BuiltFrom is the Go package the binary was built from with go install,
+	// because the release had no asset for the platform (schema 7). It is
+	// empty for binaries extracted from a release asset.
+	BuiltFrom string `json:"built_from,omitempty"`
+
+	// History lists previously installed versions kept for rollback, most
+	// recent first (schema 3). Each entry's Path is its copy in the store.
+	History []*Executable `json:"history,omitempty"`
 }
 
+// URLSource locates the releases of an executable whose Source is a download
+// URL template rather than a GitHub repository.
+type URLSource struct {
+	// ChecksumsURL is a template for a checksums file listing the asset.
+	ChecksumsURL string `json:"checksums_url,omitempty"`
+	// LatestURL is fetched to discover the latest version. It holds either
+	// the version as plain text or, with LatestPath, a JSON document.
+	LatestURL string `json:"latest_url,omitempty"`
+	// LatestPath is a JSONPath locating the version in LatestURL's JSON.
+	LatestPath string `json:"latest_path,omitempty"`
+}
+
+// UnknownVersion is recorded for an adopted executable whose version could
+// not be determined. It never matches a release, so the next update treats
+// the executable as outdated.
+const UnknownVersion = "unknown"
+
+// Install layouts.
+const (
+	// LayoutCopy installs the binary itself at Path.
+	LayoutCopy = "copy"
+	// LayoutVersioned keeps each version in the store and makes Path a
+	// symlink to the current one, so that switching versions is a rename.
+	LayoutVersioned = "versioned"
+)
+
+// Versioned reports whether the entry uses the versioned layout.
+func (e *Executable) Versioned() bool {
+	return e.Layout == LayoutVersioned
+}
+
+// Snapshot returns a copy of the entry, without its history, describing the
+// same version stored at storedPath.
+func (e *Executable) Snapshot(storedPath string) *Executable {
+	snapshot := *e
+	snapshot.Path = storedPath
+	snapshot.History = nil
+	return &snapshot
+}
+
+// PushHistory records previous as the most recent history entry, replacing
+// any older entry for the same version, and trims the history to keep
+// entries. It returns the entries that were dropped; their stored copies
+// are the caller's to delete.
+func (e *Executable) PushHistory(previous *Executable, keep int) []*Executable {
+	history := []*Executable{previous}
+	var dropped []*Executable
+	for _, entry := range e.History {
+		if entry.Version == previous.Version {
+			// The stored copy is shared with previous, so it is not dropped.
+			continue
+		}
+		history = append(history, entry)
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	if len(history) > keep {
+		dropped = append(dropped, history[keep:]...)
+		history = history[:keep]
+	}
+	e.History = history
+	return dropped
+}
+
+// RemoveHistory removes the history entry for version, if there is one, and
+// returns it.
+func (e *Executable) RemoveHistory(version string) (*Executable, bool) {
+	for i, entry := range e.History {
+		if entry.Version == version {
+			e.History = append(e.History[:i:i], e.History[i+1:]...)
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// FindHistory returns the history entry for version, or the most recent
+// entry if version is empty.
+func (e *Executable) FindHistory(version string) (*Executable, bool) {
+	for _, entry := range e.History {
+		if version == "" || entry.Version == version {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// HookResult records a run of the pre-activation hook.
+type HookResult struct {
+	Command  string    `json:"command"`
+	ExitCode int       `json:"exit_code"`
+	RanAt    time.Time `json:"ran_at"`
+}
+
+// CurrentSchemaVersion is the registry schema version written by this build.
+const CurrentSchemaVersion = 7
+
 // Registry represents the execman registry.
 type Registry struct {
 	SchemaVersion int                    `json:"schema_version"`
@@ -34,6 +171,16 @@ func DefaultRegistryPath() (string, error) {
 	return filepath.Join(configDir, "execman", "registry.json"), nil
 }
 
+// LockTimeout is how long a registry update waits for another execman
+// process to finish before giving up.
+var LockTimeout = 30 * time.Second
+
+// LockPath returns the path of the lock file guarding the registry at
+// registryPath. It is shared with the config file in the same directory.
+func LockPath(registryPath string) string {
+	return filepath.Join(filepath.Dir(registryPath), "execman.lock")
+}
+
 // Load loads the registry from the default location.
 func Load() (*Registry, error) {
 	path, err := DefaultRegistryPath()
@@ -48,21 +195,28 @@ func LoadFrom(path string) (*Registry, error) {
 	// If file doesn't exist, return a new empty registry.
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return &Registry{
-			SchemaVersion: 1,
+			SchemaVersion: CurrentSchemaVersion,
 			Executables:   make(map[string]*Executable),
 			path:          path,
 		}, nil
 	}
 
-	// #nosec G304 -- Reading user registry from trusted path
-	data, err := os.ReadFile(path)
+	reg, err := parseFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read registry: %w", err)
+		// A crash or full disk part-way through an old-style write can leave
+		// the registry truncated, so fall back to the previous version.
+		backupPath := path + atomicfile.BackupSuffix
+		backup, backupErr := parseFile(backupPath)
+		if backupErr != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\nWarning: using backup %s; the registry will be repaired on the next change.\n", err, backupPath)
+		reg = backup
 	}
 
-	var reg Registry
-	if err := json.Unmarshal(data, &reg); err != nil {
-		return nil, fmt.Errorf("failed to parse registry: %w", err)
+	if reg.SchemaVersion > CurrentSchemaVersion {
+		fmt.Fprintf(os.Stderr, "Warning: registry %s has schema version %d, newer than this execman supports (%d); it can be read but not modified.\n",
+			path, reg.SchemaVersion, CurrentSchemaVersion)
 	}
 
 	reg.path = path
@@ -70,11 +224,32 @@ func LoadFrom(path string) (*Registry, error) {
 		reg.Executables = make(map[string]*Executable)
 	}
 
-	return &reg, nil
+	return reg, nil
+}
+
+// parseFile reads and parses a registry file.
+func parseFile(path string) (*Registry, error) {
+	// #nosec G304 -- Reading user registry from trusted path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry: %w", err)
+	}
+
+	reg, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse registry %s: %w", path, err)
+	}
+	return reg, nil
 }
 
-// Save saves the registry to disk.
+// Save saves the registry to disk. It refuses to overwrite a registry with a
+// newer schema than this build understands.
 func (r *Registry) Save() error {
+	if r.SchemaVersion > CurrentSchemaVersion {
+		return fmt.Errorf("%w (schema version %d, this build supports %d); upgrade execman",
+			ErrNewerSchema, r.SchemaVersion, CurrentSchemaVersion)
+	}
+
 	// Ensure directory exists.
 	dir := filepath.Dir(r.path)
 	if err := os.MkdirAll(dir, 0750); err != nil {
@@ -86,14 +261,46 @@ func (r *Registry) Save() error {
 		return fmt.Errorf("failed to marshal registry: %w", err)
 	}
 
-	// Use 0600 permissions for registry file (user read/write only).
-	if err := os.WriteFile(r.path, data, 0600); err != nil {
+	// Use 0600 permissions for registry file (user read/write only). The
+	// previous version is kept as a backup for LoadFrom to fall back on.
+	if err := atomicfile.WriteFileWithBackup(r.path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write registry: %w", err)
 	}
 
 	return nil
 }
 
+// Update loads the registry from the default location while holding the
+// registry lock, applies fn to it and saves the result. If fn returns an
+// error, nothing is saved.
+func Update(fn func(*Registry) error) error {
+	path, err := DefaultRegistryPath()
+	if err != nil {
+		return err
+	}
+	return UpdateAt(path, fn)
+}
+
+// UpdateAt is like Update but for the registry at a specific path. Because
+// the registry is re-read under the lock, changes made by other processes
+// since it was last loaded are preserved.
+func UpdateAt(path string, fn func(*Registry) error) error {
+	l, err := lock.Acquire(LockPath(path), LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	reg, err := LoadFrom(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(reg); err != nil {
+		return err
+	}
+	return reg.Save()
+}
+
 // Add adds or updates an executable in the registry.
 func (r *Registry) Add(name string, exec *Executable) {
 	r.Executables[name] = exec
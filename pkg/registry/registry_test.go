@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestUpdateAtConcurrentGoroutines(t *testing.T) {
+	registryPath := filepath.Join(t.TempDir(), "registry.json")
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- UpdateAt(registryPath, func(r *Registry) error {
+				r.Add(fmt.Sprintf("tool-%d", i), &Executable{Version: "v1.0.0"})
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("UpdateAt returned error: %v", err)
+		}
+	}
+
+	reg, err := LoadFrom(registryPath)
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if got := len(reg.List()); got != workers {
+		t.Errorf("expected %d entries, got %d: lost updates", workers, got)
+	}
+}
+
+// TestHelperProcess is not a real test. It is run as a subprocess by
+// TestUpdateAtConcurrentProcesses to add entries from another process.
+func TestHelperProcess(t *testing.T) {
+	registryPath := os.Getenv("EXECMAN_TEST_REGISTRY")
+	if registryPath == "" {
+		t.Skip("helper process only")
+	}
+	prefix := os.Getenv("EXECMAN_TEST_PREFIX")
+	for i := range 10 {
+		err := UpdateAt(registryPath, func(r *Registry) error {
+			r.Add(fmt.Sprintf("%s-%d", prefix, i), &Executable{Version: "v1.0.0"})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("UpdateAt returned error: %v", err)
+		}
+	}
+}
+
+func TestUpdateAtConcurrentProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns subprocesses")
+	}
+	registryPath := filepath.Join(t.TempDir(), "registry.json")
+
+	const processes = 5
+	cmds := make([]*exec.Cmd, 0, processes)
+	for i := range processes {
+		// #nosec G204 -- Re-running the test binary itself
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+		cmd.Env = append(os.Environ(),
+			"EXECMAN_TEST_REGISTRY="+registryPath,
+			fmt.Sprintf("EXECMAN_TEST_PREFIX=proc%d", i))
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("failed to start helper process: %v", err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("helper process failed: %v", err)
+		}
+	}
+
+	reg, err := LoadFrom(registryPath)
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	if got := len(reg.List()); got != processes*10 {
+		t.Errorf("expected %d entries, got %d: lost updates", processes*10, got)
+	}
+}
+
+func TestLoadFromFallsBackToBackup(t *testing.T) {
+	registryPath := filepath.Join(t.TempDir(), "registry.json")
+
+	// Two saves leave the first version as the backup.
+	for _, version := range []string{"v1.0.0", "v2.0.0"} {
+		err := UpdateAt(registryPath, func(r *Registry) error {
+			r.Add("tool", &Executable{Version: version})
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("UpdateAt returned error: %v", err)
+		}
+	}
+
+	// Simulate a write torn by a crash.
+	if err := os.WriteFile(registryPath, []byte(`{"schema_version": 2, "execu`), 0600); err != nil {
+		t.Fatalf("failed to corrupt registry: %v", err)
+	}
+
+	reg, err := LoadFrom(registryPath)
+	if err != nil {
+		t.Fatalf("LoadFrom returned error: %v", err)
+	}
+	entry, ok := reg.Get("tool")
+	if !ok || entry.Version != "v1.0.0" {
+		t.Errorf("expected backup entry v1.0.0, got %+v", entry)
+	}
+
+	// Saving repairs the main file.
+	if err := reg.Save(); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if _, err := parseFile(registryPath); err != nil {
+		t.Errorf("expected registry to be repaired, got %v", err)
+	}
+}
+
+func TestLoadFromCorruptWithoutBackup(t *testing.T) {
+	registryPath := filepath.Join(t.TempDir(), "registry.json")
+	if err := os.WriteFile(registryPath, []byte("not json"), 0600); err != nil {
+		t.Fatalf("failed to write registry: %v", err)
+	}
+	if _, err := LoadFrom(registryPath); err == nil {
+		t.Error("expected error for corrupt registry without backup")
+	}
+}
+
+func TestPushHistory(t *testing.T) {
+	versions := func(entries []*Executable) string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Version)
+		}
+		return fmt.Sprint(out)
+	}
+
+	entry := &Executable{Version: "v4.0.0"}
+	for _, version := range []string{"v1.0.0", "v2.0.0", "v3.0.0"} {
+		entry.PushHistory(&Executable{Version: version}, 2)
+	}
+	if got := versions(entry.History); got != "[v3.0.0 v2.0.0]" {
+		t.Errorf("expected history [v3.0.0 v2.0.0], got %s", got)
+	}
+
+	// Pushing a version already in the history moves it to the front rather
+	// than dropping its stored copy.
+	dropped := entry.PushHistory(&Executable{Version: "v2.0.0"}, 2)
+	if got := versions(entry.History); got != "[v2.0.0 v3.0.0]" {
+		t.Errorf("expected history [v2.0.0 v3.0.0], got %s", got)
+	}
+	if len(dropped) != 0 {
+		t.Errorf("expected nothing dropped, got %s", versions(dropped))
+	}
+
+	dropped = entry.PushHistory(&Executable{Version: "v3.5.0"}, 1)
+	if got := versions(dropped); got != "[v2.0.0 v3.0.0]" {
+		t.Errorf("expected [v2.0.0 v3.0.0] dropped, got %s", got)
+	}
+
+	if latest, ok := entry.FindHistory(""); !ok || latest.Version != "v3.5.0" {
+		t.Errorf("expected most recent entry v3.5.0, got %+v", latest)
+	}
+	if _, ok := entry.FindHistory("v1.0.0"); ok {
+		t.Error("expected v1.0.0 to have been dropped")
+	}
+}
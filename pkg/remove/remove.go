@@ -6,20 +6,24 @@ import (
 	"os"
 	"strings"
 
+	"github.com/sfkleach/execman/pkg/config"
 	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/store"
 	"github.com/sfkleach/execman/pkg/symlink"
 	"github.com/spf13/cobra"
 )
 
 // Options for the remove command.
 type Options struct {
-	Name string
-	Yes  bool
+	Name    string
+	Yes     bool
+	Symlink string // The --symlink policy.
 }
 
 // NewRemoveCommand creates the remove command.
 func NewRemoveCommand() *cobra.Command {
 	var yes bool
+	var symlinkPolicy string
 
 	cmd := &cobra.Command{
 		Use:   "remove <executable>",
@@ -28,14 +32,16 @@ func NewRemoveCommand() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts := Options{
-				Name: args[0],
-				Yes:  yes,
+				Name:    args[0],
+				Yes:     yes,
+				Symlink: symlinkPolicy,
 			}
 			return Remove(opts)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+	cmd.Flags().StringVar(&symlinkPolicy, "symlink", "", "How to handle an executable that is a symlink: target, link or skip")
 
 	return cmd
 }
@@ -64,16 +70,25 @@ func Remove(opts Options) error {
 		return fmt.Errorf("failed to check path: %w", err)
 	}
 
-	if symlinkInfo != nil && symlinkInfo.IsSymlink {
-		if opts.Yes {
-			// Non-interactive mode with symlink - error out.
-			return symlink.ErrorNonInteractive(symlinkInfo.Path, symlinkInfo.Target)
+	// A versioned install's symlink is execman's own, so removing it needs
+	// no choice; the versions it links to are deleted from the store below.
+	if symlinkInfo != nil && symlinkInfo.IsSymlink && !exec.Versioned() {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
 		}
-		// Interactive mode - ask user.
-		symlinkAction = symlink.PromptAction(symlinkInfo.Path, symlinkInfo.Target)
-		if symlinkAction == symlink.ActionCancel {
+		decision, err := symlink.Decide(symlinkInfo, opts.Symlink, exec.SymlinkPolicy, cfg.SymlinkPolicy, !opts.Yes)
+		if err != nil {
+			return err
+		}
+		symlinkAction = decision.Action
+		switch symlinkAction {
+		case symlink.ActionCancel:
 			fmt.Println("Removal cancelled.")
 			return nil
+		case symlink.ActionSkip:
+			fmt.Printf("Skipping %s: %s is a symlink.\n", opts.Name, symlinkInfo.Path)
+			return nil
 		}
 		effectivePath = symlink.ResolveTarget(symlinkInfo, symlinkAction)
 	}
@@ -84,8 +99,11 @@ func Remove(opts Options) error {
 		fmt.Printf("  Source:       %s\n", exec.Source)
 		fmt.Printf("  Version:      %s\n", exec.Version)
 		fmt.Printf("  Path:         %s\n", exec.Path)
-		if symlinkInfo != nil && symlinkInfo.IsSymlink {
+		if symlinkInfo != nil && symlinkInfo.IsSymlink && !exec.Versioned() {
 			fmt.Printf("  Symlink to:   %s\n", symlinkInfo.Target)
+			if len(symlinkInfo.Chain) > 2 {
+				fmt.Printf("  Via:          %s (left in place)\n", strings.Join(symlinkInfo.Chain[1:len(symlinkInfo.Chain)-1], " -> "))
+			}
 			fmt.Printf("  Will remove:  %s\n", effectivePath)
 		}
 		fmt.Printf("  Installed:    %s\n", exec.InstalledAt.Format("2006-01-02"))
@@ -119,11 +137,32 @@ func Remove(opts Options) error {
 	}
 
 	// Remove from registry.
-	reg.Remove(opts.Name)
-	if err := reg.Save(); err != nil {
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Remove(opts.Name)
+		return nil
+	}); err != nil {
 		return fmt.Errorf("failed to update registry: %w", err)
 	}
 
+	// Previous versions kept for rollback are no longer reachable, and in
+	// the versioned layout neither is the current one.
+	stored := exec.History
+	if exec.Versioned() {
+		stored = append([]*registry.Executable{exec}, stored...)
+	}
+	if len(stored) > 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Printf("Warning: previous versions not deleted: failed to load config: %v\n", err)
+		} else {
+			for _, old := range stored {
+				if err := store.Delete(cfg.StoreDir, opts.Name, old.Version); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+			}
+		}
+	}
+
 	// Report success.
 	fmt.Printf("\n%s removed successfully\n", opts.Name)
 
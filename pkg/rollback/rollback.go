@@ -0,0 +1,200 @@
+// Package rollback restores a previously installed version of an executable
+// from the version history kept in the store.
+package rollback
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/atomicfile"
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+// Options for the rollback command.
+type Options struct {
+	Name    string
+	Version string // Empty means the most recent previous version.
+	Yes     bool
+}
+
+// NewRollbackCommand creates the rollback command.
+func NewRollbackCommand() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "rollback <executable> [version]",
+		Short: "Restore a previously installed version of an executable",
+		Long: `Restore a previously installed version of an executable from execman's version history.
+Without a version, the most recently replaced version is restored. The version being
+replaced is kept in the history, so a rollback can itself be rolled back.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := Options{
+				Name: args[0],
+				Yes:  yes,
+			}
+			if len(args) > 1 {
+				opts.Version = args[1]
+			}
+			return Run(opts)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+// Run executes the rollback command.
+func Run(opts Options) error {
+	// Load registry and config.
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	exec, ok := reg.Get(opts.Name)
+	if !ok {
+		return fmt.Errorf("executable %q is not managed by execman", opts.Name)
+	}
+
+	if opts.Version == exec.Version {
+		fmt.Printf("%s is already at %s.\n", opts.Name, exec.Version)
+		return nil
+	}
+
+	entry, ok := exec.FindHistory(opts.Version)
+	if !ok {
+		if opts.Version != "" {
+			return fmt.Errorf("version %s of %s is not in the version history", opts.Version, opts.Name)
+		}
+		return fmt.Errorf("no previous versions of %s are kept", opts.Name)
+	}
+
+	// Refuse to restore a stored copy that has been tampered with.
+	if entry.Checksum != "" {
+		if err := archive.VerifyChecksum(entry.Path, entry.Checksum); err != nil {
+			return fmt.Errorf("stored copy of %s %s failed verification: %w", opts.Name, entry.Version, err)
+		}
+	}
+
+	// A symlink is followed so that the file it points at is replaced,
+	// unless it is the versioned layout's own link.
+	targetPath := exec.Path
+	if !exec.Versioned() {
+		if resolved, err := filepath.EvalSymlinks(exec.Path); err == nil {
+			targetPath = resolved
+		}
+	}
+	_, statErr := os.Stat(targetPath)
+	currentExists := statErr == nil
+
+	if !opts.Yes {
+		fmt.Printf("Roll back %s from %s to %s?\n\n", opts.Name, exec.Version, entry.Version)
+		fmt.Printf("  Path:         %s\n", exec.Path)
+		fmt.Printf("  Installed:    %s\n", entry.InstalledAt.Format("2006-01-02"))
+		fmt.Println()
+		fmt.Print("Continue? [y/N]: ")
+
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.ToLower(strings.TrimSpace(response))
+
+		if response != "y" && response != "yes" {
+			fmt.Println("Rollback cancelled.")
+			return nil
+		}
+	}
+
+	// Keep the version being replaced, so that the rollback can be undone.
+	var current *registry.Executable
+	var replacement *atomicfile.Replacement
+	if exec.Versioned() {
+		// Both versions are already in the store, so only the link changes.
+		if currentExists {
+			current = exec.Snapshot(store.Path(cfg.StoreDir, opts.Name, exec.Version))
+		}
+		replacement, err = atomicfile.ReplaceSymlink(entry.Path, exec.Path)
+		if err != nil {
+			return fmt.Errorf("failed to switch %s to %s: %w", opts.Name, entry.Version, err)
+		}
+	} else {
+		if currentExists && cfg.KeepVersions > 0 {
+			stored, err := store.Save(cfg.StoreDir, opts.Name, exec.Version, targetPath)
+			if err != nil {
+				return fmt.Errorf("failed to keep current version: %w", err)
+			}
+			current = exec.Snapshot(stored)
+		}
+
+		replacement, err = atomicfile.ReplaceExecutable(entry.Path, targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s %s: %w", opts.Name, entry.Version, err)
+		}
+	}
+
+	// The restored entry keeps the current path and upstream identity, which
+	// may have been updated since the old version was installed.
+	restored := entry.Snapshot(exec.Path)
+	restored.Source = exec.Source
+	restored.RepoID = exec.RepoID
+	restored.Layout = exec.Layout
+	if !restored.Versioned() {
+		// The stored copy was installed afresh; a versioned switch is not.
+		restored.InstalledAt = time.Now()
+	}
+	for _, h := range exec.History {
+		if h != entry {
+			restored.History = append(restored.History, h)
+		}
+	}
+	var dropped []*registry.Executable
+	if current != nil {
+		dropped = restored.PushHistory(current, cfg.KeepVersions)
+	}
+
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Add(opts.Name, restored)
+		return nil
+	}); err != nil {
+		if restoreErr := replacement.Restore(); restoreErr != nil {
+			return fmt.Errorf("failed to update registry: %w (and failed to restore previous executable: %v)", err, restoreErr)
+		}
+		return fmt.Errorf("failed to update registry (previous executable restored): %w", err)
+	}
+	if err := replacement.Commit(); err != nil {
+		fmt.Printf("Warning: failed to remove previous executable: %v\n", err)
+	}
+
+	// A copy of the restored version is no longer needed, and neither is
+	// anything trimmed from the history to make room for the replaced
+	// version. In the versioned layout the stored copy is the current binary.
+	if !restored.Versioned() {
+		dropped = append(dropped, entry)
+	}
+	for _, old := range dropped {
+		if err := store.Delete(cfg.StoreDir, opts.Name, old.Version); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	if restored.Versioned() {
+		fmt.Printf("\n%s switched to %s\n", opts.Name, entry.Version)
+	} else {
+		fmt.Printf("\n%s rolled back to %s\n", opts.Name, entry.Version)
+	}
+	return nil
+}
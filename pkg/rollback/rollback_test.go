@@ -0,0 +1,266 @@
+package rollback
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/store"
+)
+
+// setup isolates the config and registry and keeps a copy of tool in the
+// store for each of versions, holding the version itself. It returns the
+// store directory and the install path.
+func setup(t *testing.T, versions ...string) (string, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	src := filepath.Join(home, "src")
+	for _, version := range versions {
+		if err := os.WriteFile(src, []byte(version), 0755); err != nil {
+			t.Fatalf("failed to write %s: %v", version, err)
+		}
+		if _, err := store.Save(cfg.StoreDir, "tool", version, src); err != nil {
+			t.Fatalf("failed to store %s: %v", version, err)
+		}
+	}
+
+	path := filepath.Join(home, "bin", "tool")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create bin directory: %v", err)
+	}
+	return cfg.StoreDir, path
+}
+
+// stored returns a history entry for the stored copy of version.
+func stored(t *testing.T, storeDir, version string) *registry.Executable {
+	t.Helper()
+	path := store.Path(storeDir, "tool", version)
+	checksum, err := archive.CalculateChecksum(path)
+	if err != nil {
+		t.Fatalf("failed to checksum %s: %v", version, err)
+	}
+	return &registry.Executable{Source: "https://github.com/acme/tool", Version: version, Path: path, Checksum: checksum}
+}
+
+// register records exec as tool.
+func register(t *testing.T, exec *registry.Executable) {
+	t.Helper()
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Add("tool", exec)
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to save registry: %v", err)
+	}
+}
+
+// loadEntry returns tool's registry entry.
+func loadEntry(t *testing.T) *registry.Executable {
+	t.Helper()
+	reg, err := registry.Load()
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+	exec, found := reg.Get("tool")
+	if !found {
+		t.Fatal("tool is not in the registry")
+	}
+	return exec
+}
+
+// historyVersions lists the versions in exec's history, most recent first.
+func historyVersions(exec *registry.Executable) string {
+	var versions []string
+	for _, h := range exec.History {
+		versions = append(versions, h.Version)
+	}
+	return strings.Join(versions, " ")
+}
+
+// readFile returns the content of path, or the error reading it.
+func readFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func TestRunCopyLayout(t *testing.T) {
+	storeDir, path := setup(t, "v1.0.0")
+	if err := os.WriteFile(path, []byte("v2.0.0"), 0755); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+	register(t, &registry.Executable{
+		Source:  "https://github.com/acme/tool",
+		Version: "v2.0.0",
+		Path:    path,
+		History: []*registry.Executable{stored(t, storeDir, "v1.0.0")},
+	})
+
+	if err := Run(Options{Name: "tool", Yes: true}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if got := readFile(path); got != "v1.0.0" {
+		t.Errorf("installed executable = %q, want v1.0.0", got)
+	}
+	exec := loadEntry(t)
+	if exec.Version != "v1.0.0" || exec.Path != path || historyVersions(exec) != "v2.0.0" {
+		t.Errorf("registry records %s at %s with history %q, want v1.0.0 at %s with history v2.0.0",
+			exec.Version, exec.Path, historyVersions(exec), path)
+	}
+	if got := readFile(exec.History[0].Path); got != "v2.0.0" {
+		t.Errorf("kept copy of v2.0.0 = %q", got)
+	}
+	// The restored copy is installed, so the store no longer needs it.
+	if _, err := os.Stat(store.Path(storeDir, "tool", "v1.0.0")); !os.IsNotExist(err) {
+		t.Errorf("expected the stored copy of v1.0.0 to be deleted, got %v", err)
+	}
+}
+
+func TestRunVersionedSwitch(t *testing.T) {
+	storeDir, path := setup(t, "v1.0.0", "v2.0.0")
+	if err := os.Symlink(store.Path(storeDir, "tool", "v2.0.0"), path); err != nil {
+		t.Fatalf("failed to link executable: %v", err)
+	}
+	current := stored(t, storeDir, "v2.0.0")
+	current.Path = path
+	current.Layout = registry.LayoutVersioned
+	current.History = []*registry.Executable{stored(t, storeDir, "v1.0.0")}
+	register(t, current)
+
+	if err := Run(Options{Name: "tool", Version: "v1.0.0", Yes: true}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if target, err := os.Readlink(path); err != nil || target != store.Path(storeDir, "tool", "v1.0.0") {
+		t.Errorf("link points at %q (%v), want the stored v1.0.0", target, err)
+	}
+	exec := loadEntry(t)
+	if exec.Version != "v1.0.0" || !exec.Versioned() || historyVersions(exec) != "v2.0.0" {
+		t.Errorf("registry records %s (%s) with history %q, want versioned v1.0.0 with history v2.0.0",
+			exec.Version, exec.Layout, historyVersions(exec))
+	}
+	// Both versions stay in the store, one current and one for switching back.
+	for _, version := range []string{"v1.0.0", "v2.0.0"} {
+		if got := readFile(store.Path(storeDir, "tool", version)); got != version {
+			t.Errorf("stored %s = %q", version, got)
+		}
+	}
+}
+
+func TestRunRejectsTamperedCopy(t *testing.T) {
+	storeDir, path := setup(t, "v1.0.0")
+	if err := os.WriteFile(path, []byte("v2.0.0"), 0755); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+	register(t, &registry.Executable{
+		Source:  "https://github.com/acme/tool",
+		Version: "v2.0.0",
+		Path:    path,
+		History: []*registry.Executable{stored(t, storeDir, "v1.0.0")},
+	})
+	if err := os.WriteFile(store.Path(storeDir, "tool", "v1.0.0"), []byte("tampered"), 0755); err != nil {
+		t.Fatalf("failed to tamper with stored copy: %v", err)
+	}
+
+	err := Run(Options{Name: "tool", Yes: true})
+	if err == nil || !strings.Contains(err.Error(), "failed verification") {
+		t.Fatalf("expected a verification error, got %v", err)
+	}
+	if got := readFile(path); got != "v2.0.0" {
+		t.Errorf("installed executable = %q, want v2.0.0 left alone", got)
+	}
+	if exec := loadEntry(t); exec.Version != "v2.0.0" {
+		t.Errorf("registry records %s, want v2.0.0", exec.Version)
+	}
+}
+
+func TestRunTrimsHistory(t *testing.T) {
+	storeDir, path := setup(t, "v1.0.0", "v2.0.0")
+	if err := config.Update(func(cfg *config.Config) error {
+		cfg.KeepVersions = 1
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("v3.0.0"), 0755); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+	register(t, &registry.Executable{
+		Source:  "https://github.com/acme/tool",
+		Version: "v3.0.0",
+		Path:    path,
+		History: []*registry.Executable{stored(t, storeDir, "v2.0.0"), stored(t, storeDir, "v1.0.0")},
+	})
+
+	if err := Run(Options{Name: "tool", Yes: true}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	// Only the replaced v3.0.0 fits in the history, so v1.0.0 goes.
+	exec := loadEntry(t)
+	if exec.Version != "v2.0.0" || historyVersions(exec) != "v3.0.0" {
+		t.Errorf("registry records %s with history %q, want v2.0.0 with history v3.0.0", exec.Version, historyVersions(exec))
+	}
+	versions, err := store.Versions(storeDir, "tool")
+	if err != nil {
+		t.Fatalf("Versions returned error: %v", err)
+	}
+	if strings.Join(versions, " ") != "v3.0.0" {
+		t.Errorf("store holds %v, want only v3.0.0", versions)
+	}
+}
+
+func TestRunRestoresOnRegistryFailure(t *testing.T) {
+	storeDir, path := setup(t, "v1.0.0")
+	if err := os.WriteFile(path, []byte("v2.0.0"), 0755); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+	register(t, &registry.Executable{
+		Source:  "https://github.com/acme/tool",
+		Version: "v2.0.0",
+		Path:    path,
+		History: []*registry.Executable{stored(t, storeDir, "v1.0.0")},
+	})
+
+	// A registry from a newer execman can be read but not saved.
+	registryPath, err := registry.DefaultRegistryPath()
+	if err != nil {
+		t.Fatalf("DefaultRegistryPath returned error: %v", err)
+	}
+	reg, err := registry.LoadFrom(registryPath)
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+	reg.SchemaVersion = registry.CurrentSchemaVersion + 1
+	data, err := json.Marshal(reg)
+	if err != nil {
+		t.Fatalf("failed to marshal registry: %v", err)
+	}
+	if err := os.WriteFile(registryPath, data, 0600); err != nil {
+		t.Fatalf("failed to write registry: %v", err)
+	}
+
+	if err := Run(Options{Name: "tool", Yes: true}); err == nil {
+		t.Fatal("expected an error saving the registry")
+	}
+	if got := readFile(path); got != "v2.0.0" {
+		t.Errorf("installed executable = %q, want v2.0.0 restored", got)
+	}
+	if got := readFile(store.Path(storeDir, "tool", "v1.0.0")); got != "v1.0.0" {
+		t.Errorf("stored v1.0.0 = %q, want it kept", got)
+	}
+}
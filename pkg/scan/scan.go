@@ -0,0 +1,267 @@
+// Package scan finds executables in install directories that execman does
+// not manage, and managed executables whose files have gone.
+package scan
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+// Unmanaged is an executable found in a scanned directory that is not in
+// the registry.
+type Unmanaged struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// Module is the Go main module path embedded in the binary, if any.
+	Module string `json:"module,omitempty"`
+	// Source and Version are the suggested arguments for execman adopt.
+	Source  string `json:"source,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// AdoptCommand returns a ready-to-run adopt command, or an empty string if
+// no source could be suggested.
+func (u *Unmanaged) AdoptCommand() string {
+	if u.Source == "" {
+		return ""
+	}
+	command := fmt.Sprintf("execman adopt %s --source %s", u.Path, u.Source)
+	if u.Version != "" {
+		command += " --version " + u.Version
+	}
+	return command
+}
+
+// Missing is a registry entry whose file no longer exists.
+type Missing struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Report is the result of a scan.
+type Report struct {
+	Directories []string    `json:"directories"`
+	Unmanaged   []Unmanaged `json:"unmanaged"`
+	Missing     []Missing   `json:"missing"`
+}
+
+// NewScanCommand creates the scan command.
+func NewScanCommand() *cobra.Command {
+	var includePath bool
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:   "scan [directory]",
+		Short: "Find executables that execman does not manage",
+		Long: `List executables in the install directory (or the given directory) that are not in the
+registry, and registry entries whose file is missing. For Go binaries, the module path
+embedded in the binary is used to suggest an 'execman adopt' command.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var dir string
+			if len(args) > 0 {
+				dir = args[0]
+			}
+			return runScan(dir, includePath, jsonOutput)
+		},
+	}
+
+	cmd.Flags().BoolVar(&includePath, "path", false, "Also scan every directory on PATH")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output as JSON")
+
+	return cmd
+}
+
+func runScan(dir string, includePath, jsonOutput bool) error {
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	if dir == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		dir = cfg.DefaultInstallDir
+	}
+	dirs := []string{dir}
+	if includePath {
+		dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+	}
+
+	report, err := Scan(dirs, reg)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	printReport(report)
+	return nil
+}
+
+// Scan lists the executables in dirs that are not in reg, and the entries
+// of reg whose files are missing. Directories that do not exist are
+// skipped, and each directory is scanned once however often it is listed.
+func Scan(dirs []string, reg *registry.Registry) (*Report, error) {
+	report := &Report{
+		Unmanaged: []Unmanaged{},
+		Missing:   []Missing{},
+	}
+
+	// A managed executable may be reached through a symlink, including the
+	// versioned layout's link into the store, so compare resolved paths.
+	managed := make(map[string]bool)
+	names := reg.List()
+	sort.Strings(names)
+	for _, name := range names {
+		exec, _ := reg.Get(name)
+		managed[filepath.Clean(exec.Path)] = true
+		resolved, err := filepath.EvalSymlinks(exec.Path)
+		if err != nil {
+			report.Missing = append(report.Missing, Missing{Name: name, Path: exec.Path})
+			continue
+		}
+		managed[resolved] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+		}
+		if seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		entries, err := os.ReadDir(abs)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", abs, err)
+		}
+		report.Directories = append(report.Directories, abs)
+
+		for _, entry := range entries {
+			// Hidden files include execman's own staging files.
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(abs, entry.Name())
+			if managed[path] {
+				continue
+			}
+			resolved, err := filepath.EvalSymlinks(path)
+			if err != nil || managed[resolved] {
+				continue
+			}
+			if !isExecutable(resolved) {
+				continue
+			}
+			report.Unmanaged = append(report.Unmanaged, identify(entry.Name(), path))
+		}
+	}
+
+	return report, nil
+}
+
+// isExecutable reports whether path is a regular file that can be run.
+func isExecutable(path string) bool {
+	fileInfo, err := os.Stat(path)
+	if err != nil || !fileInfo.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(path), ".exe")
+	}
+	return fileInfo.Mode().Perm()&0111 != 0
+}
+
+// pseudoVersion matches Go pseudo-versions, which name a commit rather than
+// a release.
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}`)
+
+// identify describes an unmanaged executable, suggesting its source from
+// any Go build information it carries.
+func identify(name, path string) Unmanaged {
+	u := Unmanaged{Name: name, Path: path}
+
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		// Not a Go binary, or one built without module support.
+		return u
+	}
+	u.Module = info.Main.Path
+	if u.Module == "" {
+		u.Module = info.Path
+	}
+	u.Source = SourceFromModule(u.Module)
+
+	version := info.Main.Version
+	if u.Source != "" && version != "" && version != "(devel)" &&
+		!strings.Contains(version, "+") && !pseudoVersion.MatchString(version) {
+		u.Version = version
+	}
+	return u
+}
+
+// SourceFromModule returns the GitHub repository a Go module path lives in,
+// such as github.com/owner/repo for github.com/owner/repo/v2/cmd/tool, or an
+// empty string if the module is not hosted on GitHub.
+func SourceFromModule(module string) string {
+	parts := strings.Split(module, "/")
+	if len(parts) < 3 || parts[0] != "github.com" || parts[1] == "" || parts[2] == "" {
+		return ""
+	}
+	return strings.Join(parts[:3], "/")
+}
+
+func printReport(report *Report) {
+	if len(report.Unmanaged) == 0 {
+		fmt.Printf("No unmanaged executables in %s.\n", strings.Join(report.Directories, ", "))
+	} else {
+		fmt.Println("Unmanaged executables:")
+		for _, u := range report.Unmanaged {
+			fmt.Printf("\n  %s\n", u.Path)
+			switch {
+			case u.Source != "":
+				fmt.Printf("    Likely source: %s (Go module %s)\n", u.Source, u.Module)
+				fmt.Printf("    %s\n", u.AdoptCommand())
+			case u.Module != "":
+				fmt.Printf("    Go module %s is not on GitHub\n", u.Module)
+			default:
+				fmt.Println("    No Go build information; adopt with --source if you know where it came from")
+			}
+		}
+	}
+
+	if len(report.Missing) > 0 {
+		fmt.Println("\nManaged executables whose file is missing:")
+		for _, m := range report.Missing {
+			fmt.Printf("  %-20s %s\n", m.Name, m.Path)
+		}
+		fmt.Println("\nReinstall them with 'execman update <name>' or stop tracking them with 'execman forget <name>'.")
+	}
+}
@@ -0,0 +1,92 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+func TestSourceFromModule(t *testing.T) {
+	tests := []struct {
+		module string
+		want   string
+	}{
+		{module: "github.com/sfkleach/execman", want: "github.com/sfkleach/execman"},
+		{module: "github.com/owner/repo/v2/cmd/tool", want: "github.com/owner/repo"},
+		{module: "github.com/owner", want: ""},
+		{module: "golang.org/x/tools/cmd/stringer", want: ""},
+		{module: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := SourceFromModule(tt.module); got != tt.want {
+			t.Errorf("SourceFromModule(%q) = %q, want %q", tt.module, got, tt.want)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on Unix permission bits")
+	}
+	dir := t.TempDir()
+
+	writeFile := func(name string, data []byte, mode os.FileMode) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, mode); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	// The test binary is a Go binary built from this module, which makes it
+	// a convenient unmanaged executable with build information.
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to find test binary: %v", err)
+	}
+	// #nosec G304 -- Reading the running test binary
+	selfData, err := os.ReadFile(self)
+	if err != nil {
+		t.Fatalf("failed to read test binary: %v", err)
+	}
+
+	managedPath := writeFile("managed", []byte("#!/bin/sh\n"), 0755)
+	goBinary := writeFile("gotool", selfData, 0755)
+	script := writeFile("script", []byte("#!/bin/sh\n"), 0755)
+	writeFile("README", []byte("not executable"), 0644)
+	writeFile(".managed.execman-old", []byte("#!/bin/sh\n"), 0755)
+
+	reg := &registry.Registry{Executables: map[string]*registry.Executable{
+		"managed": {Path: managedPath},
+		"gone":    {Path: filepath.Join(dir, "gone")},
+	}}
+
+	// Listing the directory twice must not report anything twice.
+	report, err := Scan([]string{dir, dir, filepath.Join(dir, "missing-dir")}, reg)
+	if err != nil {
+		t.Fatalf("Scan returned error: %v", err)
+	}
+
+	if len(report.Unmanaged) != 2 {
+		t.Fatalf("expected 2 unmanaged executables, got %+v", report.Unmanaged)
+	}
+	found := make(map[string]Unmanaged)
+	for _, u := range report.Unmanaged {
+		found[u.Path] = u
+	}
+	if u := found[goBinary]; u.Source != "github.com/sfkleach/execman" {
+		t.Errorf("expected source github.com/sfkleach/execman for Go binary, got %+v", u)
+	}
+	if u, ok := found[script]; !ok || u.Source != "" || u.AdoptCommand() != "" {
+		t.Errorf("expected script without a suggested source, got %+v", u)
+	}
+
+	if len(report.Missing) != 1 || report.Missing[0].Name != "gone" {
+		t.Errorf("expected gone to be reported missing, got %+v", report.Missing)
+	}
+}
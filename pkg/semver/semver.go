@@ -0,0 +1,217 @@
+// Package semver parses semantic versions, as used in release tags, and
+// matches them against version constraints.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version. A leading "v" is accepted and
+// ignored, as are build metadata suffixes.
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          string
+	// parts is how many of major, minor and patch were given.
+	parts int
+}
+
+// Parse parses a version such as v1.2.3, 1.2.3-rc.1 or v1.2. Missing minor
+// and patch numbers are zero.
+func Parse(s string) (*Version, error) {
+	rest := strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		rest = rest[:i]
+	}
+	v := &Version{}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		v.Prerelease = rest[i+1:]
+		rest = rest[:i]
+		if v.Prerelease == "" {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+	}
+
+	fields := strings.Split(rest, ".")
+	if len(fields) > 3 {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = n
+	}
+	v.parts = len(fields)
+	return v, nil
+}
+
+// String formats the version without a "v" prefix.
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as v is less than, equal to or greater than w.
+// A prerelease sorts before the release it precedes.
+func (v *Version) Compare(w *Version) int {
+	for _, pair := range [][2]int{{v.Major, w.Major}, {v.Minor, w.Minor}, {v.Patch, w.Patch}} {
+		if pair[0] != pair[1] {
+			return compareInts(pair[0], pair[1])
+		}
+	}
+	switch {
+	case v.Prerelease == w.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case w.Prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.Prerelease, w.Prerelease)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// comparePrerelease compares dot-separated prerelease identifiers: numeric
+// identifiers numerically and below alphanumeric ones, which compare as
+// strings.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return compareInts(an, bn)
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInts(len(as), len(bs))
+}
+
+// Constraint is a set of conditions a version must all satisfy.
+type Constraint struct {
+	conditions []condition
+	text       string
+}
+
+type condition struct {
+	op      string // One of =, !=, >, >=, <, <=.
+	version *Version
+}
+
+// ParseConstraint parses a constraint made of conditions separated by commas
+// or spaces, all of which must hold. A condition is a version optionally
+// preceded by =, !=, >, >=, <, <=, ^ (compatible: same major version, or same
+// minor version below 1.0) or ~ (same minor version). A bare partial version
+// such as 1.2 matches any 1.2.x, and * or an empty constraint matches
+// anything.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{text: s}
+	for _, term := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if term == "*" {
+			continue
+		}
+		op := ""
+		for _, prefix := range []string{">=", "<=", "!=", "==", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(term, prefix) {
+				op = prefix
+				break
+			}
+		}
+		v, err := Parse(strings.TrimSpace(term[len(op):]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+		}
+		c.conditions = append(c.conditions, expand(op, v)...)
+	}
+	return c, nil
+}
+
+// expand turns one term into plain comparisons.
+func expand(op string, v *Version) []condition {
+	next := func(major, minor int) *Version {
+		return &Version{Major: major, Minor: minor, Prerelease: "0", parts: 3}
+	}
+	switch op {
+	case "^":
+		upper := next(v.Major+1, 0)
+		if v.Major == 0 && v.parts > 1 {
+			upper = next(0, v.Minor+1)
+		}
+		return []condition{{">=", v}, {"<", upper}}
+	case "~":
+		upper := next(v.Major, v.Minor+1)
+		if v.parts == 1 {
+			upper = next(v.Major+1, 0)
+		}
+		return []condition{{">=", v}, {"<", upper}}
+	case "", "=", "==":
+		// A partial version stands for the whole range it names.
+		switch v.parts {
+		case 1:
+			return []condition{{">=", v}, {"<", next(v.Major+1, 0)}}
+		case 2:
+			return []condition{{">=", v}, {"<", next(v.Major, v.Minor+1)}}
+		}
+		return []condition{{"=", v}}
+	default:
+		return []condition{{op, v}}
+	}
+}
+
+// Check reports whether v satisfies every condition of the constraint.
+func (c *Constraint) Check(v *Version) bool {
+	for _, cond := range c.conditions {
+		cmp := v.Compare(cond.version)
+		var ok bool
+		switch cond.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the constraint as it was written.
+func (c *Constraint) String() string {
+	return c.text
+}
@@ -0,0 +1,79 @@
+package semver
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "v1.2.3", b: "1.2.3", want: 0},
+		{a: "v1.2.3", b: "v1.10.0", want: -1},
+		{a: "v2.0.0", b: "v1.99.99", want: 1},
+		{a: "v1.0.0-rc.1", b: "v1.0.0", want: -1},
+		{a: "v1.0.0-rc.2", b: "v1.0.0-rc.10", want: -1},
+		{a: "v1.0.0-alpha", b: "v1.0.0-1", want: 1},
+		{a: "v1.0.0+build.5", b: "v1.0.0", want: 0},
+	}
+
+	for _, tt := range tests {
+		a, err := Parse(tt.a)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.a, err)
+		}
+		b, err := Parse(tt.b)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.b, err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, s := range []string{"", "latest", "v1.2.3.4", "v1.x", "v1.2.3-"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) expected error", s)
+		}
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{constraint: "", version: "v9.9.9", want: true},
+		{constraint: "*", version: "v0.0.1", want: true},
+		{constraint: "v1.2.3", version: "v1.2.3", want: true},
+		{constraint: "v1.2.3", version: "v1.2.4", want: false},
+		{constraint: "1.2", version: "v1.2.9", want: true},
+		{constraint: "1.2", version: "v1.3.0", want: false},
+		{constraint: "^1.2", version: "v1.9.0", want: true},
+		{constraint: "^1.2", version: "v2.0.0", want: false},
+		{constraint: "^1.2", version: "v2.0.0-rc.1", want: false},
+		{constraint: "^0.3.1", version: "v0.3.5", want: true},
+		{constraint: "^0.3.1", version: "v0.4.0", want: false},
+		{constraint: "~1.2.3", version: "v1.2.9", want: true},
+		{constraint: "~1.2.3", version: "v1.3.0", want: false},
+		{constraint: ">=1.0, <1.5", version: "v1.4.9", want: true},
+		{constraint: ">=1.0 <1.5", version: "v1.5.0", want: false},
+		{constraint: "!=1.4.0", version: "v1.4.0", want: false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q) returned error: %v", tt.constraint, err)
+		}
+		v, err := Parse(tt.version)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.version, err)
+		}
+		if got := c.Check(v); got != tt.want {
+			t.Errorf("%q.Check(%s) = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package source
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonStep is one step of a JSONPath: a member name, or an array index when
+// isIndex is set. Negative indexes count from the end.
+type jsonStep struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath parses the subset of JSONPath needed to pick a version out of
+// a release index: $ followed by .name, ['name'] and [index] steps, such as
+// $.current_version or $.versions[-1].name.
+func parseJSONPath(expr string) ([]jsonStep, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(expr), "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", expr)
+	}
+
+	var steps []jsonStep
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty member name", expr)
+			}
+			steps = append(steps, jsonStep{name: rest[:end]})
+			rest = rest[end:]
+		case rest[0] == '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unterminated [", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, jsonStep{name: inner[1 : len(inner)-1]})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSONPath %q: unsupported selector [%s]", expr, inner)
+			}
+			steps = append(steps, jsonStep{index: index, isIndex: true})
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// evaluate follows steps through a decoded JSON document.
+func evaluate(doc any, steps []jsonStep) (any, error) {
+	value := doc
+	for _, step := range steps {
+		if step.isIndex {
+			array, ok := value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot index [%d]: not an array", step.index)
+			}
+			i := step.index
+			if i < 0 {
+				i += len(array)
+			}
+			if i < 0 || i >= len(array) {
+				return nil, fmt.Errorf("index [%d] out of range", step.index)
+			}
+			value = array[i]
+			continue
+		}
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot select %q: not an object", step.name)
+		}
+		value, ok = object[step.name]
+		if !ok {
+			return nil, fmt.Errorf("no member %q", step.name)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,501 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/httpclient"
+	"github.com/sfkleach/execman/pkg/semver"
+)
+
+// OCIScheme prefixes sources that are repositories in an OCI registry.
+const OCIScheme = "oci://"
+
+// Media types of the manifests and indexes execman understands.
+const (
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// annotationTitle names the file a layer holds, as set by ORAS.
+const annotationTitle = "org.opencontainers.image.title"
+
+// maxManifestSize bounds the manifests, indexes and tag lists read from a
+// registry.
+const maxManifestSize = 4 << 20
+
+// maxTagPages bounds how many pages of a tag list are followed.
+const maxTagPages = 100
+
+// repositoryPattern matches a repository name as the distribution
+// specification defines it.
+var repositoryPattern = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+
+// OCIProvider downloads releases published as artifacts in an OCI registry,
+// such as those pushed with ORAS. Each release is a tag. A tag may be an
+// index with a manifest per platform, or a single manifest with a layer per
+// platform, each titled like a GitHub release asset, e.g.
+// tool_linux_amd64.tar.gz.
+type OCIProvider struct {
+	source     string
+	host       string
+	repository string
+	baseURL    string
+	token      string
+}
+
+// descriptor is a reference to a manifest or blob.
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *platform         `json:"platform,omitempty"`
+}
+
+// platform is the platform an index entry's manifest is for.
+type platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+}
+
+// manifest holds the fields execman reads from either a manifest or an index.
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// IsOCI reports whether source is a repository in an OCI registry.
+func IsOCI(source string) bool {
+	return strings.HasPrefix(source, OCIScheme)
+}
+
+// NewOCIProvider returns a provider for a source such as
+// oci://registry.example.com/tools/deployctl.
+func NewOCIProvider(source string) (*OCIProvider, error) {
+	host, repository, ok := strings.Cut(strings.TrimPrefix(source, OCIScheme), "/")
+	if !ok || host == "" || repository == "" {
+		return nil, fmt.Errorf("OCI source must be oci://registry/repository")
+	}
+	if !repositoryPattern.MatchString(repository) {
+		return nil, fmt.Errorf("invalid OCI repository name %q", repository)
+	}
+	if _, err := url.Parse("https://" + host); err != nil || strings.ContainsAny(host, "@/?#") {
+		return nil, fmt.Errorf("invalid OCI registry %q", host)
+	}
+
+	// Like other registry clients, talk plain HTTP only to the local host.
+	scheme := "https"
+	if hostname := hostOnly(host); hostname == "localhost" || net.ParseIP(hostname).IsLoopback() {
+		scheme = "http"
+	}
+	return &OCIProvider{
+		source:     OCIScheme + host + "/" + repository,
+		host:       host,
+		repository: repository,
+		baseURL:    scheme + "://" + host + "/v2/" + repository,
+	}, nil
+}
+
+// hostOnly strips any port from a registry host.
+func hostOnly(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// Source implements Provider.
+func (p *OCIProvider) Source() string {
+	return p.source
+}
+
+// Name implements Provider. It is the last part of the repository name.
+func (p *OCIProvider) Name() string {
+	return path.Base(p.repository)
+}
+
+// Latest implements Provider. It is the tag with the highest semantic
+// version; tags that are not versions, such as "latest", are ignored.
+func (p *OCIProvider) Latest(includePrereleases bool) (string, error) {
+	tags, err := p.tags()
+	if err != nil {
+		return "", err
+	}
+
+	var latest string
+	var latestVersion *semver.Version
+	for _, tag := range tags {
+		v, err := semver.Parse(tag)
+		if err != nil || (v.Prerelease != "" && !includePrereleases) {
+			continue
+		}
+		if latestVersion == nil || v.Compare(latestVersion) > 0 {
+			latest, latestVersion = tag, v
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no version tags found in %s", p.source)
+	}
+	return latest, nil
+}
+
+// tags lists the repository's tags, following the registry's pagination.
+func (p *OCIProvider) tags() ([]string, error) {
+	var tags []string
+	next := p.baseURL + "/tags/list"
+	for page := 0; next != "" && page < maxTagPages; page++ {
+		resp, err := p.get(next, "application/json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags: %w", err)
+		}
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&list)
+		link := resp.Header.Get("Link")
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tag list: %w", err)
+		}
+		tags = append(tags, list.Tags...)
+
+		next, err = nextPage(resp.Request.URL, link)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// nextPage returns the URL of the next page from a Link header such as
+// </v2/tools/deployctl/tags/list?n=100&last=v1.2.0>; rel="next", or "" if
+// there is none.
+func nextPage(base *url.URL, link string) (string, error) {
+	if link == "" {
+		return "", nil
+	}
+	target, params, _ := strings.Cut(link, ";")
+	if !strings.Contains(params, `rel="next"`) {
+		return "", nil
+	}
+	ref, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+	if err != nil {
+		return "", fmt.Errorf("invalid tag list link %q: %w", link, err)
+	}
+	next := base.ResolveReference(ref)
+	if next.Host != base.Host {
+		return "", fmt.Errorf("tag list link %q leaves the registry", link)
+	}
+	return next.String(), nil
+}
+
+// Download implements Provider. The layer for the platform is fetched from
+// the registry and must match the digest its manifest gives.
+func (p *OCIProvider) Download(version, goos, goarch, dir string) (*Download, error) {
+	m, digest, err := p.manifest(version, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// An index has a manifest per platform.
+	byPlatform := false
+	if len(m.Manifests) > 0 {
+		var chosen *descriptor
+		for i, d := range m.Manifests {
+			if d.Platform != nil && d.Platform.OS == goos && d.Platform.Architecture == goarch {
+				chosen = &m.Manifests[i]
+				break
+			}
+		}
+		if chosen == nil {
+			return nil, fmt.Errorf("%w for %s/%s in %s:%s", github.ErrNoMatchingAsset, goos, goarch, p.source, version)
+		}
+		if m, digest, err = p.manifest(chosen.Digest, chosen.Digest); err != nil {
+			return nil, err
+		}
+		byPlatform = true
+	}
+
+	layer, name, err := p.selectLayer(m.Layers, byPlatform, goos, goarch)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s:%s", err, p.source, version)
+	}
+
+	blobURL := p.baseURL + "/blobs/" + layer.Digest
+	dl := &Download{
+		Path:            filepath.Join(dir, name),
+		AssetName:       name,
+		AssetURL:        blobURL,
+		ArchiveChecksum: layer.Digest,
+		ChecksumSource:  p.source + "@" + digest,
+	}
+	fmt.Printf("Downloading %s from %s...\n", name, p.host)
+	if err := p.fetchBlob(blobURL, layer, dl.Path); err != nil {
+		return nil, err
+	}
+	dl.DownloadedAt = time.Now()
+	fmt.Println("Digest verified.")
+	return dl, nil
+}
+
+// selectLayer picks the layer holding the platform's asset and the file name
+// to save it under. A manifest chosen by platform may have a single layer;
+// otherwise layers are matched by their titles.
+func (p *OCIProvider) selectLayer(layers []descriptor, byPlatform bool, goos, goarch string) (*descriptor, string, error) {
+	if len(layers) == 0 {
+		return nil, "", fmt.Errorf("%w: manifest has no layers", github.ErrNoMatchingAsset)
+	}
+	if byPlatform && len(layers) == 1 {
+		layer := &layers[0]
+		name := layer.Annotations[annotationTitle]
+		if name == "" {
+			name = p.Name()
+		}
+		return layer, safeName(name, p.Name()), nil
+	}
+
+	var assets []github.Asset
+	titled := make(map[string]*descriptor)
+	for i, layer := range layers {
+		if title := layer.Annotations[annotationTitle]; title != "" {
+			assets = append(assets, github.Asset{Name: title})
+			titled[title] = &layers[i]
+		}
+	}
+	asset, err := github.FindAsset(assets, goos, goarch)
+	if err != nil {
+		return nil, "", err
+	}
+	return titled[asset.Name], safeName(asset.Name, p.Name()), nil
+}
+
+// safeName reduces a layer title to a plain file name.
+func safeName(title, fallback string) string {
+	name := path.Base(strings.ReplaceAll(title, `\`, "/"))
+	if name == "" || name == "." || name == "/" || name == ".." {
+		return fallback
+	}
+	return name
+}
+
+// manifest fetches the manifest or index a tag or digest refers to. When
+// expected is set the manifest must have that digest. The digest of what
+// was fetched is returned with it.
+func (p *OCIProvider) manifest(reference, expected string) (*manifest, string, error) {
+	accept := strings.Join([]string{mediaTypeOCIIndex, mediaTypeOCIManifest, mediaTypeDockerList, mediaTypeDockerManifest}, ", ")
+	resp, err := p.get(p.baseURL+"/manifests/"+reference, accept)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return nil, "", fmt.Errorf("version %s not found in %s", reference, p.source)
+		}
+		return nil, "", fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if len(data) > maxManifestSize {
+		return nil, "", fmt.Errorf("manifest for %s is too large", reference)
+	}
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if expected != "" && digest != expected {
+		return nil, "", fmt.Errorf("manifest digest mismatch for %s: got %s", expected, digest)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, "", fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	mediaType := m.MediaType
+	if mediaType == "" {
+		mediaType, _, _ = strings.Cut(resp.Header.Get("Content-Type"), ";")
+	}
+	switch mediaType {
+	case mediaTypeOCIIndex, mediaTypeDockerList:
+		m.Layers = nil
+	case mediaTypeOCIManifest, mediaTypeDockerManifest:
+		m.Manifests = nil
+	default:
+		return nil, "", fmt.Errorf("unsupported manifest type %q for %s", mediaType, reference)
+	}
+	return &m, digest, nil
+}
+
+// fetchBlob downloads a layer to dest, checking its size and digest.
+func (p *OCIProvider) fetchBlob(blobURL string, layer *descriptor, dest string) error {
+	algorithm, expected, _ := strings.Cut(layer.Digest, ":")
+	if algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest algorithm in %s", layer.Digest)
+	}
+
+	resp, err := p.get(blobURL, "")
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", layer.Digest, err)
+	}
+	defer resp.Body.Close()
+
+	// #nosec G304 -- Writing into execman's own temp directory
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, hash), io.LimitReader(resp.Body, layer.Size+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", layer.Digest, err)
+	}
+
+	fmt.Println("Verifying digest...")
+	if n != layer.Size {
+		return fmt.Errorf("size mismatch for %s: expected %d bytes, got %d", layer.Digest, layer.Size, n)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != expected {
+		return fmt.Errorf("digest verification failed for %s", layer.Digest)
+	}
+	return nil
+}
+
+// errNotFound is returned by get when the registry has no such resource.
+var errNotFound = errors.New("not found")
+
+// get requests a registry URL, fetching an anonymous bearer token when the
+// registry asks for one.
+func (p *OCIProvider) get(target, accept string) (*http.Response, error) {
+	resp, err := p.do(target, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && p.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if p.token, err = fetchToken(challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = p.do(target, accept); err != nil {
+			return nil, err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		resp.Body.Close()
+		return nil, fmt.Errorf("access to %s denied (status %d)", p.source, resp.StatusCode)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry error (status %d) for %s", resp.StatusCode, target)
+	}
+}
+
+// do sends one GET request to the registry.
+func (p *OCIProvider) do(target, accept string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	return httpclient.Client().Do(req)
+}
+
+// fetchToken requests an anonymous token as a Bearer challenge such as
+// Bearer realm="https://auth.example.com/token",service="registry",scope="..."
+// directs.
+func fetchToken(challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("registry requires authentication that execman does not support")
+	}
+	values := make(map[string]string)
+	for _, param := range splitChallenge(params) {
+		key, value, ok := strings.Cut(param, "=")
+		if ok {
+			values[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || (realm.Scheme != "https" && realm.Scheme != "http") {
+		return "", fmt.Errorf("registry gave an invalid token realm %q", values["realm"])
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if values[key] != "" {
+			query.Set(key, values[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	// #nosec G107 -- The realm is where the registry directs token requests
+	resp, err := httpclient.Client().Get(realm.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch registry token: status %d", resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse registry token: %w", err)
+	}
+	if body.Token == "" {
+		body.Token = body.AccessToken
+	}
+	if body.Token == "" {
+		return "", fmt.Errorf("registry gave an empty token")
+	}
+	return body.Token, nil
+}
+
+// splitChallenge splits challenge parameters at commas outside quotes, since
+// a scope such as repository:tools/deployctl:pull,push contains commas.
+func splitChallenge(params string) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i, c := range params {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			parts = append(parts, params[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, params[start:])
+}
@@ -0,0 +1,292 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/github"
+)
+
+// testRegistry is an in-process registry serving the parts of the OCI
+// distribution API that execman uses. It hands out anonymous tokens, as
+// most registries do, and pages its tag list two tags at a time.
+type testRegistry struct {
+	server    *httptest.Server
+	repo      string
+	tags      []string
+	manifests map[string]testManifest // By tag and by digest.
+	blobs     map[string][]byte
+}
+
+type testManifest struct {
+	mediaType string
+	data      []byte
+}
+
+const testToken = "anonymous-token"
+
+func newTestRegistry(t *testing.T, repo string) *testRegistry {
+	t.Helper()
+	r := &testRegistry{
+		repo:      repo,
+		manifests: make(map[string]testManifest),
+		blobs:     make(map[string][]byte),
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if req.URL.Query().Get("scope") != "repository:"+r.repo+":pull" {
+			http.Error(w, "bad scope", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": testToken})
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer "+testToken {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.server.URL+`/token",service="test",scope="repository:`+r.repo+`:pull"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(req.URL.Path, "/v2/"+r.repo+"/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	switch {
+	case rest == "tags/list":
+		start := 0
+		if last := req.URL.Query().Get("last"); last != "" {
+			for i, tag := range r.tags {
+				if tag == last {
+					start = i + 1
+				}
+			}
+		}
+		end := min(start+2, len(r.tags))
+		if end < len(r.tags) {
+			w.Header().Set("Link", `</v2/`+r.repo+`/tags/list?n=2&last=`+r.tags[end-1]+`>; rel="next"`)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": r.repo, "tags": r.tags[start:end]})
+	case strings.HasPrefix(rest, "manifests/"):
+		m, found := r.manifests[strings.TrimPrefix(rest, "manifests/")]
+		if !found {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		_, _ = w.Write(m.data)
+	case strings.HasPrefix(rest, "blobs/"):
+		blob, found := r.blobs[strings.TrimPrefix(rest, "blobs/")]
+		if !found {
+			http.NotFound(w, req)
+			return
+		}
+		_, _ = w.Write(blob)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// source returns the oci:// source for the registry's repository.
+func (r *testRegistry) source() string {
+	return OCIScheme + strings.TrimPrefix(r.server.URL, "http://") + "/" + r.repo
+}
+
+// addBlob stores content and returns its descriptor.
+func (r *testRegistry) addBlob(content []byte, title string) descriptor {
+	sum := sha256.Sum256(content)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	r.blobs[digest] = content
+	d := descriptor{MediaType: "application/octet-stream", Digest: digest, Size: int64(len(content))}
+	if title != "" {
+		d.Annotations = map[string]string{annotationTitle: title}
+	}
+	return d
+}
+
+// addManifest stores a manifest or index under its digest, and under tag
+// if one is given, returning its descriptor.
+func (r *testRegistry) addManifest(t *testing.T, tag string, m manifest) descriptor {
+	t.Helper()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("failed to marshal manifest: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	stored := testManifest{mediaType: m.MediaType, data: data}
+	r.manifests[digest] = stored
+	if tag != "" {
+		r.manifests[tag] = stored
+		r.tags = append(r.tags, tag)
+	}
+	return descriptor{MediaType: m.MediaType, Digest: digest, Size: int64(len(data))}
+}
+
+func TestNewOCIProvider(t *testing.T) {
+	p, err := NewOCIProvider("oci://registry.local/tools/deployctl")
+	if err != nil {
+		t.Fatalf("NewOCIProvider returned error: %v", err)
+	}
+	if got := p.Name(); got != "deployctl" {
+		t.Errorf("Name() = %q, want deployctl", got)
+	}
+	if p.baseURL != "https://registry.local/v2/tools/deployctl" {
+		t.Errorf("baseURL = %q, want https", p.baseURL)
+	}
+
+	local, err := NewOCIProvider("oci://localhost:5000/deployctl")
+	if err != nil {
+		t.Fatalf("NewOCIProvider returned error: %v", err)
+	}
+	if !strings.HasPrefix(local.baseURL, "http://") {
+		t.Errorf("baseURL = %q, want plain HTTP for the local host", local.baseURL)
+	}
+
+	for _, bad := range []string{"oci://registry.local", "oci://registry.local/", "oci:///tools/deployctl", "oci://registry.local/Tools/Deployctl", "oci://registry.local/tools/../etc"} {
+		if _, err := NewOCIProvider(bad); err == nil {
+			t.Errorf("NewOCIProvider(%q) expected an error", bad)
+		}
+	}
+}
+
+func TestOCIProviderLatest(t *testing.T) {
+	r := newTestRegistry(t, "tools/deployctl")
+	r.tags = []string{"v1.0.0", "latest", "v1.10.0", "v1.2.0", "v2.0.0-rc.1"}
+
+	p, err := NewOCIProvider(r.source())
+	if err != nil {
+		t.Fatalf("NewOCIProvider returned error: %v", err)
+	}
+
+	got, err := p.Latest(false)
+	if err != nil {
+		t.Fatalf("Latest returned error: %v", err)
+	}
+	if got != "v1.10.0" {
+		t.Errorf("Latest(false) = %q, want v1.10.0", got)
+	}
+
+	got, err = p.Latest(true)
+	if err != nil {
+		t.Fatalf("Latest returned error: %v", err)
+	}
+	if got != "v2.0.0-rc.1" {
+		t.Errorf("Latest(true) = %q, want v2.0.0-rc.1", got)
+	}
+}
+
+func TestOCIProviderDownloadIndex(t *testing.T) {
+	r := newTestRegistry(t, "tools/deployctl")
+	linux := []byte("linux binary")
+	linuxLayer := r.addBlob(linux, "deployctl")
+	linuxManifest := r.addManifest(t, "", manifest{MediaType: mediaTypeOCIManifest, Layers: []descriptor{linuxLayer}})
+	linuxManifest.Platform = &platform{OS: "linux", Architecture: "amd64"}
+	r.addManifest(t, "v1.0.0", manifest{MediaType: mediaTypeOCIIndex, Manifests: []descriptor{linuxManifest}})
+
+	p, err := NewOCIProvider(r.source())
+	if err != nil {
+		t.Fatalf("NewOCIProvider returned error: %v", err)
+	}
+
+	dir := t.TempDir()
+	dl, err := p.Download("v1.0.0", "linux", "amd64", dir)
+	if err != nil {
+		t.Fatalf("Download returned error: %v", err)
+	}
+	if dl.AssetName != "deployctl" {
+		t.Errorf("AssetName = %q, want deployctl", dl.AssetName)
+	}
+	if dl.ArchiveChecksum != linuxLayer.Digest {
+		t.Errorf("ArchiveChecksum = %q, want %q", dl.ArchiveChecksum, linuxLayer.Digest)
+	}
+	if want := r.source() + "@" + linuxManifest.Digest; dl.ChecksumSource != want {
+		t.Errorf("ChecksumSource = %q, want %q", dl.ChecksumSource, want)
+	}
+	data, err := os.ReadFile(dl.Path)
+	if err != nil {
+		t.Fatalf("failed to read download: %v", err)
+	}
+	if string(data) != string(linux) {
+		t.Errorf("downloaded %q, want %q", data, linux)
+	}
+
+	if _, err := p.Download("v1.0.0", "darwin", "arm64", t.TempDir()); !errors.Is(err, github.ErrNoMatchingAsset) {
+		t.Errorf("Download for a missing platform returned %v, want ErrNoMatchingAsset", err)
+	}
+	if _, err := p.Download("v9.9.9", "linux", "amd64", t.TempDir()); err == nil {
+		t.Error("Download of a missing version expected an error")
+	}
+}
+
+func TestOCIProviderDownloadTitledLayers(t *testing.T) {
+	r := newTestRegistry(t, "deployctl")
+	amd64 := r.addBlob([]byte("amd64 archive"), "deployctl_linux_amd64.tar.gz")
+	arm64 := r.addBlob([]byte("arm64 archive"), "deployctl_linux_arm64.tar.gz")
+	r.addManifest(t, "v2.1.0", manifest{MediaType: mediaTypeOCIManifest, Layers: []descriptor{amd64, arm64}})
+
+	p, err := NewOCIProvider(r.source())
+	if err != nil {
+		t.Fatalf("NewOCIProvider returned error: %v", err)
+	}
+
+	dl, err := p.Download("v2.1.0", "linux", "arm64", t.TempDir())
+	if err != nil {
+		t.Fatalf("Download returned error: %v", err)
+	}
+	if dl.AssetName != "deployctl_linux_arm64.tar.gz" || dl.ArchiveChecksum != arm64.Digest {
+		t.Errorf("Download chose %s (%s), want the arm64 layer", dl.AssetName, dl.ArchiveChecksum)
+	}
+	if _, err := p.Download("v2.1.0", "windows", "amd64", t.TempDir()); !errors.Is(err, github.ErrNoMatchingAsset) {
+		t.Errorf("Download for a missing platform returned %v, want ErrNoMatchingAsset", err)
+	}
+}
+
+func TestOCIProviderRejectsTamperedBlob(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(r *testRegistry, layer descriptor)
+	}{
+		{
+			name: "changed content",
+			tamper: func(r *testRegistry, layer descriptor) {
+				r.blobs[layer.Digest] = []byte("evil archive!")
+			},
+		},
+		{
+			name: "extra content",
+			tamper: func(r *testRegistry, layer descriptor) {
+				r.blobs[layer.Digest] = append(r.blobs[layer.Digest], "more"...)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry(t, "deployctl")
+			layer := r.addBlob([]byte("good archive!"), "deployctl_linux_amd64.tar.gz")
+			r.addManifest(t, "v1.0.0", manifest{MediaType: mediaTypeOCIManifest, Layers: []descriptor{layer}})
+			tt.tamper(r, layer)
+
+			p, err := NewOCIProvider(r.source())
+			if err != nil {
+				t.Fatalf("NewOCIProvider returned error: %v", err)
+			}
+			if _, err := p.Download("v1.0.0", "linux", "amd64", t.TempDir()); err == nil {
+				t.Fatal("expected a verification error")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package source finds and downloads releases from sources other than GitHub
+// releases. Each kind of source is a Provider; GitHub sources are handled by
+// the github package directly.
+package source
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+// Provider finds and downloads the releases of one executable.
+type Provider interface {
+	// Source returns the source as recorded in the registry.
+	Source() string
+	// Name returns the executable name the source implies, or "" if it
+	// implies none.
+	Name() string
+	// Latest returns the newest available version.
+	Latest(includePrereleases bool) (string, error)
+	// Download fetches the release asset of version for a platform into dir
+	// and verifies it where the source allows. A release with no asset for
+	// the platform gives an error wrapping github.ErrNoMatchingAsset.
+	Download(version, goos, goarch, dir string) (*Download, error)
+}
+
+// Download describes a downloaded release asset.
+type Download struct {
+	Path            string // Local path of the asset.
+	AssetName       string
+	AssetURL        string
+	ArchiveChecksum string
+	ChecksumSource  string // Empty if no checksum was published for the asset.
+	DownloadedAt    time.Time
+}
+
+// IsURL reports whether source is a download URL template rather than a
+// GitHub repository.
+func IsURL(source string) bool {
+	return (strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")) &&
+		(strings.Contains(source, "{version}") || strings.Contains(source, "{bare_version}"))
+}
+
+// HasProvider reports whether source is handled by a Provider rather than
+// being a GitHub repository.
+func HasProvider(source string) bool {
+	return IsURL(source) || IsOCI(source)
+}
+
+// Lookup returns the provider for source, configured by spec where the
+// source needs it, or nil if source is a GitHub repository.
+func Lookup(source string, spec *registry.URLSource) (Provider, error) {
+	switch {
+	case IsURL(source):
+		return NewURLProvider(source, spec)
+	case IsOCI(source):
+		if spec != nil {
+			return nil, fmt.Errorf("latest-version and checksums URLs only apply to download URL templates, not %s", source)
+		}
+		return NewOCIProvider(source)
+	}
+	return nil, nil
+}
+
+// ForExecutable returns the provider for a registry entry, or nil if it
+// comes from GitHub.
+func ForExecutable(exec *registry.Executable) (Provider, error) {
+	p, err := Lookup(exec.Source, exec.URLSource)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source %s: %w", exec.Source, err)
+	}
+	return p, nil
+}
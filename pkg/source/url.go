@@ -0,0 +1,254 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/httpclient"
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+// maxLatestSize bounds the document fetched to discover the latest version.
+const maxLatestSize = 1 << 20
+
+// URLProvider downloads releases from a URL template such as
+// https://releases.example.com/tool/{version}/tool_{version}_{os}_{arch}.zip.
+// Templates may use {version}, {bare_version} (the version without a leading
+// "v"), {os} and {arch}, with Go's names for operating systems and
+// architectures.
+type URLProvider struct {
+	template string
+	spec     registry.URLSource
+}
+
+// NewURLProvider returns a provider for a URL template. spec may be nil, in
+// which case the latest version cannot be discovered.
+func NewURLProvider(template string, spec *registry.URLSource) (*URLProvider, error) {
+	if !strings.Contains(template, "{version}") && !strings.Contains(template, "{bare_version}") {
+		return nil, fmt.Errorf("URL template must contain {version} or {bare_version}")
+	}
+	if err := checkTemplate(template); err != nil {
+		return nil, err
+	}
+	p := &URLProvider{template: template}
+	if spec != nil {
+		p.spec = *spec
+	}
+	if p.spec.ChecksumsURL != "" {
+		if err := checkTemplate(p.spec.ChecksumsURL); err != nil {
+			return nil, err
+		}
+	}
+	if p.spec.LatestPath != "" {
+		if p.spec.LatestURL == "" {
+			return nil, fmt.Errorf("a latest-version JSONPath needs a latest-version URL")
+		}
+		if _, err := parseJSONPath(p.spec.LatestPath); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// checkTemplate rejects placeholders that expand would leave in place.
+func checkTemplate(template string) error {
+	rest := template
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			return nil
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return fmt.Errorf("unterminated placeholder in %s", template)
+		}
+		switch name := rest[start+1 : start+end]; name {
+		case "version", "bare_version", "os", "arch":
+		default:
+			return fmt.Errorf("unknown placeholder {%s} in %s", name, template)
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
+// expand fills in a template's placeholders.
+func expand(template, version, goos, goarch string) string {
+	return strings.NewReplacer(
+		"{version}", version,
+		"{bare_version}", strings.TrimPrefix(version, "v"),
+		"{os}", goos,
+		"{arch}", goarch,
+	).Replace(template)
+}
+
+// Source implements Provider.
+func (p *URLProvider) Source() string {
+	return p.template
+}
+
+// Spec returns the settings recorded alongside the template in the registry.
+func (p *URLProvider) Spec() *registry.URLSource {
+	spec := p.spec
+	return &spec
+}
+
+// Name implements Provider. It is the part of the template's file name before
+// the first placeholder, such as "tool" for tool_{version}_{os}_{arch}.zip.
+func (p *URLProvider) Name() string {
+	name := path.Base(p.template)
+	if i := strings.Index(name, "{"); i >= 0 {
+		name = name[:i]
+	} else if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return strings.TrimRight(name, "_-.")
+}
+
+// Latest implements Provider. A URL source publishes a single latest
+// version, so includePrereleases has no effect.
+func (p *URLProvider) Latest(includePrereleases bool) (string, error) {
+	if p.spec.LatestURL == "" {
+		return "", fmt.Errorf("no latest-version URL is configured for %s; give the version explicitly", p.template)
+	}
+
+	// #nosec G107 -- The URL is configured by the user for this source
+	resp, err := httpclient.Client().Get(p.spec.LatestURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch latest version: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch latest version from %s: status %d", p.spec.LatestURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLatestSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read latest version: %w", err)
+	}
+
+	var version string
+	if p.spec.LatestPath != "" {
+		version, err = latestFromJSON(data, p.spec.LatestPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to find latest version in %s: %w", p.spec.LatestURL, err)
+		}
+	} else {
+		version, _, _ = strings.Cut(strings.TrimSpace(string(data)), "\n")
+		version = strings.TrimSpace(version)
+	}
+	if version == "" || strings.ContainsAny(version, "/?#{} \t") {
+		return "", fmt.Errorf("%s does not give a usable version: %q", p.spec.LatestURL, version)
+	}
+	return version, nil
+}
+
+// latestFromJSON evaluates a JSONPath against a JSON document, requiring a
+// string or number.
+func latestFromJSON(data []byte, jsonPath string) (string, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	steps, err := parseJSONPath(jsonPath)
+	if err != nil {
+		return "", err
+	}
+	value, err := evaluate(doc, steps)
+	if err != nil {
+		return "", err
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("%s is not a string", jsonPath)
+	}
+}
+
+// Download implements Provider.
+func (p *URLProvider) Download(version, goos, goarch, dir string) (*Download, error) {
+	assetURL := expand(p.template, version, goos, goarch)
+	parsed, err := url.Parse(assetURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid download URL %s: %w", assetURL, err)
+	}
+	assetName := path.Base(parsed.Path)
+	if assetName == "" || assetName == "/" || assetName == "." {
+		return nil, fmt.Errorf("download URL %s does not name a file", assetURL)
+	}
+
+	dl := &Download{
+		Path:      filepath.Join(dir, assetName),
+		AssetName: assetName,
+		AssetURL:  assetURL,
+	}
+	fmt.Printf("Downloading %s...\n", assetURL)
+	if err := fetch(assetURL, dl.Path); err != nil {
+		return nil, err
+	}
+	dl.DownloadedAt = time.Now()
+	if dl.ArchiveChecksum, err = archive.CalculateChecksum(dl.Path); err != nil {
+		return nil, fmt.Errorf("failed to calculate checksum: %w", err)
+	}
+
+	// A checksums file that was configured must list the asset.
+	if p.spec.ChecksumsURL != "" {
+		checksumsURL := expand(p.spec.ChecksumsURL, version, goos, goarch)
+		checksumsPath := filepath.Join(dir, "checksums.txt")
+		fmt.Println("Downloading checksums...")
+		if err := fetch(checksumsURL, checksumsPath); err != nil {
+			return nil, fmt.Errorf("failed to download checksums: %w", err)
+		}
+		expected, err := archive.FindChecksumInFile(checksumsPath, assetName)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("Verifying checksum...")
+		if expected != dl.ArchiveChecksum {
+			return nil, fmt.Errorf("checksum verification failed for %s", assetName)
+		}
+		dl.ChecksumSource = checksumsURL
+		fmt.Println("Checksum verified.")
+	}
+	return dl, nil
+}
+
+// fetch downloads url to dest. A missing file means the release has no asset
+// for the platform, so it is reported as such.
+func fetch(url, dest string) error {
+	// #nosec G107 -- The URL is expanded from a template configured by the user
+	resp, err := httpclient.Client().Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s not found", github.ErrNoMatchingAsset, url)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("download of %s failed with status %d", url, resp.StatusCode)
+	}
+
+	// #nosec G304 -- Writing into execman's own temp directory
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dest, err)
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,160 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+func TestURLProviderName(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{template: "https://releases.example.com/terraform/{bare_version}/terraform_{bare_version}_{os}_{arch}.zip", want: "terraform"},
+		{template: "https://dl.example.com/release/{version}/bin/{os}/{arch}/kubectl", want: "kubectl"},
+		{template: "https://example.com/{version}/tool-{version}-{os}-{arch}.tar.gz", want: "tool"},
+		{template: "https://example.com/{version}/{os}-{arch}.tar.gz", want: ""},
+	}
+
+	for _, tt := range tests {
+		p, err := NewURLProvider(tt.template, nil)
+		if err != nil {
+			t.Fatalf("NewURLProvider(%q) returned error: %v", tt.template, err)
+		}
+		if got := p.Name(); got != tt.want {
+			t.Errorf("Name() for %s = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestNewURLProviderRejectsBadTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		spec     *registry.URLSource
+	}{
+		{name: "no version", template: "https://example.com/tool_{os}_{arch}.zip"},
+		{name: "unknown placeholder", template: "https://example.com/{version}/tool_{platform}.zip"},
+		{name: "unterminated", template: "https://example.com/{version}/tool_{os"},
+		{name: "bad checksums template", template: "https://example.com/{version}/tool.zip", spec: &registry.URLSource{ChecksumsURL: "https://example.com/{release}/SHA256SUMS"}},
+		{name: "path without url", template: "https://example.com/{version}/tool.zip", spec: &registry.URLSource{LatestPath: "$.version"}},
+		{name: "bad path", template: "https://example.com/{version}/tool.zip", spec: &registry.URLSource{LatestURL: "https://example.com/latest", LatestPath: "version"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewURLProvider(tt.template, tt.spec); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestURLProviderLatest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stable.txt":
+			_, _ = w.Write([]byte("v1.31.0\n"))
+		case "/index.json":
+			_, _ = w.Write([]byte(`{"product": "tool", "current_version": "1.9.2", "versions": [{"name": "1.8.0"}, {"name": "1.9.2"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		spec    *registry.URLSource
+		want    string
+		wantErr bool
+	}{
+		{name: "plain text", spec: &registry.URLSource{LatestURL: server.URL + "/stable.txt"}, want: "v1.31.0"},
+		{name: "json member", spec: &registry.URLSource{LatestURL: server.URL + "/index.json", LatestPath: "$.current_version"}, want: "1.9.2"},
+		{name: "json array", spec: &registry.URLSource{LatestURL: server.URL + "/index.json", LatestPath: "$.versions[-1]['name']"}, want: "1.9.2"},
+		{name: "json object", spec: &registry.URLSource{LatestURL: server.URL + "/index.json", LatestPath: "$.versions"}, wantErr: true},
+		{name: "missing member", spec: &registry.URLSource{LatestURL: server.URL + "/index.json", LatestPath: "$.latest"}, wantErr: true},
+		{name: "not found", spec: &registry.URLSource{LatestURL: server.URL + "/missing"}, wantErr: true},
+		{name: "not configured", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewURLProvider(server.URL+"/{version}/tool.zip", tt.spec)
+			if err != nil {
+				t.Fatalf("NewURLProvider returned error: %v", err)
+			}
+			got, err := p.Latest(false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Latest returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Latest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLProviderDownload(t *testing.T) {
+	asset := []byte("\x7fELF tool")
+	sum := sha256.Sum256(asset)
+	checksums := hex.EncodeToString(sum[:]) + "  tool_1.2.0_linux_amd64\n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.2.0/tool_1.2.0_linux_amd64":
+			_, _ = w.Write(asset)
+		case "/v1.2.0/SHA256SUMS":
+			_, _ = w.Write([]byte(checksums))
+		case "/v1.2.0/BADSUMS":
+			_, _ = w.Write([]byte(strings.Repeat("0", 64) + "  tool_1.2.0_linux_amd64\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	template := server.URL + "/{version}/tool_{bare_version}_{os}_{arch}"
+	p, err := NewURLProvider(template, &registry.URLSource{ChecksumsURL: server.URL + "/{version}/SHA256SUMS"})
+	if err != nil {
+		t.Fatalf("NewURLProvider returned error: %v", err)
+	}
+
+	dl, err := p.Download("v1.2.0", "linux", "amd64", t.TempDir())
+	if err != nil {
+		t.Fatalf("Download returned error: %v", err)
+	}
+	if dl.AssetName != "tool_1.2.0_linux_amd64" || dl.ChecksumSource != server.URL+"/v1.2.0/SHA256SUMS" {
+		t.Errorf("unexpected download %+v", dl)
+	}
+	if data, _ := os.ReadFile(dl.Path); string(data) != string(asset) {
+		t.Errorf("downloaded %q, want %q", data, asset)
+	}
+
+	if _, err := p.Download("v1.2.0", "plan9", "amd64", t.TempDir()); !errors.Is(err, github.ErrNoMatchingAsset) {
+		t.Errorf("expected ErrNoMatchingAsset for a missing platform, got %v", err)
+	}
+
+	bad, err := NewURLProvider(template, &registry.URLSource{ChecksumsURL: server.URL + "/{version}/BADSUMS"})
+	if err != nil {
+		t.Fatalf("NewURLProvider returned error: %v", err)
+	}
+	if _, err := bad.Download("v1.2.0", "linux", "amd64", t.TempDir()); err == nil {
+		t.Error("expected a checksum mismatch to fail")
+	}
+}
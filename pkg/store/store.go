@@ -0,0 +1,87 @@
+// Package store manages execman's private copies of executables, laid out
+// as <root>/<name>/<version>/<name>.
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sfkleach/execman/pkg/atomicfile"
+)
+
+// Path returns where the given version of an executable is kept.
+func Path(root, name, version string) string {
+	return filepath.Join(root, name, version, name)
+}
+
+// validate rejects names and versions that would escape the store when used
+// as path components.
+func validate(name, version string) error {
+	for _, part := range []string{name, version} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return fmt.Errorf("cannot store %s %s: invalid path component %q", name, version, part)
+		}
+	}
+	return nil
+}
+
+// Save copies the executable at src into the store as the given version and
+// returns the stored path. An existing copy of that version is replaced.
+func Save(root, name, version, src string) (string, error) {
+	if err := validate(name, version); err != nil {
+		return "", err
+	}
+	dest := Path(root, name, version)
+	// #nosec G301 -- Stored executables must be runnable, so directories need 0755
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("failed to create store directory: %w", err)
+	}
+	replacement, err := atomicfile.ReplaceExecutable(src, dest)
+	if err != nil {
+		return "", fmt.Errorf("failed to store %s %s: %w", name, version, err)
+	}
+	if err := replacement.Commit(); err != nil {
+		return "", fmt.Errorf("failed to store %s %s: %w", name, version, err)
+	}
+	return dest, nil
+}
+
+// Delete removes one stored version of an executable.
+func Delete(root, name, version string) error {
+	if err := validate(name, version); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(root, name, version)); err != nil {
+		return fmt.Errorf("failed to delete stored %s %s: %w", name, version, err)
+	}
+	// Tidy up the executable's directory once it holds no versions.
+	_ = os.Remove(filepath.Join(root, name))
+	return nil
+}
+
+// Versions lists the versions of an executable present in the store, sorted
+// by name.
+func Versions(root, name string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(root, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(Path(root, name, entry.Name())); err == nil {
+			versions = append(versions, entry.Name())
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
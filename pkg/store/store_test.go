@@ -0,0 +1,62 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveAndDelete(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "store")
+	src := filepath.Join(tmpDir, "tool")
+	if err := os.WriteFile(src, []byte("v1"), 0600); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+
+	stored, err := Save(root, "tool", "v1.0.0", src)
+	if err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if stored != Path(root, "tool", "v1.0.0") {
+		t.Errorf("expected stored path %s, got %s", Path(root, "tool", "v1.0.0"), stored)
+	}
+	// #nosec G304 -- Reading file created by the test
+	if got, err := os.ReadFile(stored); err != nil || string(got) != "v1" {
+		t.Errorf("expected stored copy to contain v1, got %q (%v)", got, err)
+	}
+	// The source is copied, not moved.
+	if _, err := os.Stat(src); err != nil {
+		t.Errorf("expected source to remain: %v", err)
+	}
+
+	if _, err := Save(root, "tool", "v2.0.0", src); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	versions, err := Versions(root, "tool")
+	if err != nil {
+		t.Fatalf("Versions returned error: %v", err)
+	}
+	if want := []string{"v1.0.0", "v2.0.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("expected versions %v, got %v", want, versions)
+	}
+
+	for _, version := range versions {
+		if err := Delete(root, "tool", version); err != nil {
+			t.Fatalf("Delete returned error: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "tool")); !os.IsNotExist(err) {
+		t.Errorf("expected empty executable directory to be removed")
+	}
+}
+
+func TestSaveRejectsPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	for _, version := range []string{"", "..", "../escape", `v1\..`} {
+		if _, err := Save(root, "tool", version, "unused"); err == nil {
+			t.Errorf("expected error for version %q", version)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package symlink
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -18,18 +20,101 @@ const (
 	ActionReplaceTarget
 	// ActionReplaceSymlink indicates the symlink itself should be replaced.
 	ActionReplaceSymlink
+	// ActionSkip indicates the executable should be left alone without
+	// treating it as a failure.
+	ActionSkip
 )
 
+// Policy names, as used by the --symlink flag, the config and the registry.
+const (
+	PolicyTarget = "target"
+	PolicyLink   = "link"
+	PolicySkip   = "skip"
+)
+
+// ParsePolicy returns the action for a symlink policy name.
+func ParsePolicy(name string) (SymlinkAction, error) {
+	switch name {
+	case PolicyTarget:
+		return ActionReplaceTarget, nil
+	case PolicyLink:
+		return ActionReplaceSymlink, nil
+	case PolicySkip:
+		return ActionSkip, nil
+	default:
+		return ActionCancel, fmt.Errorf("unknown symlink policy %q (expected %s, %s or %s)", name, PolicyTarget, PolicyLink, PolicySkip)
+	}
+}
+
+// PolicyName returns the symlink policy name for an action, or an empty
+// string for ActionCancel.
+func PolicyName(action SymlinkAction) string {
+	switch action {
+	case ActionReplaceTarget:
+		return PolicyTarget
+	case ActionReplaceSymlink:
+		return PolicyLink
+	case ActionSkip:
+		return PolicySkip
+	default:
+		return ""
+	}
+}
+
+// Decision is the chosen way of handling a symlink.
+type Decision struct {
+	Action SymlinkAction
+	// Prompted is true if the user chose interactively, in which case the
+	// choice may be worth remembering.
+	Prompted bool
+}
+
+// Decide chooses how to handle the symlink described by info. The first
+// policy that is set wins: flag (from --symlink), then recorded (saved for
+// the executable in the registry), then configured (the config default).
+// Only if none is set is the user asked, and in non-interactive mode that
+// is an error.
+func Decide(info *Info, flag, recorded, configured string, interactive bool) (*Decision, error) {
+	for _, name := range []string{flag, recorded, configured} {
+		if name == "" {
+			continue
+		}
+		action, err := ParsePolicy(name)
+		if err != nil {
+			return nil, err
+		}
+		return &Decision{Action: action}, nil
+	}
+
+	if !interactive {
+		return nil, ErrorNonInteractive(info.Path, info.Target)
+	}
+	return &Decision{Action: PromptAction(info), Prompted: true}, nil
+}
+
 // Info contains information about a symlink.
 type Info struct {
 	// IsSymlink indicates whether the path is a symbolic link.
 	IsSymlink bool
 	// Path is the original path (the symlink itself).
 	Path string
-	// Target is the resolved path that the symlink points to.
+	// Target is the file the symlink ultimately resolves to, after following
+	// every link in the chain. Relative link values are resolved against the
+	// directory of the link containing them.
 	Target string
+	// Chain lists every path visited from Path to Target inclusive, so a
+	// single symlink has a chain of two.
+	Chain []string
 }
 
+// MaxChainLength is the most links Check will follow before deciding the
+// chain is too long, matching the limit most kernels apply.
+const MaxChainLength = 40
+
+// ErrLoop is returned by Check when a chain of symlinks leads back to a link
+// already visited.
+var ErrLoop = errors.New("symlink loop")
+
 // Check checks if the given path is a symbolic link and returns information about it.
 func Check(path string) (*Info, error) {
 	info := &Info{
@@ -50,21 +135,75 @@ func Check(path string) (*Info, error) {
 	// Check if it's a symlink.
 	if fileInfo.Mode()&os.ModeSymlink != 0 {
 		info.IsSymlink = true
-		// Resolve the target.
-		target, err := os.Readlink(path)
+		target, chain, err := follow(path)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read symlink target: %w", err)
+			return nil, err
 		}
 		info.Target = target
+		info.Chain = chain
 	}
 
 	return info, nil
 }
 
+// follow resolves a chain of symlinks starting at path, returning the final
+// path and every path visited. The final path need not exist.
+func follow(path string) (string, []string, error) {
+	current := filepath.Clean(path)
+	chain := []string{current}
+	visited := map[string]bool{current: true}
+
+	for {
+		fileInfo, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			// A dangling link: report where it points.
+			return current, chain, nil
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to stat path: %w", err)
+		}
+		if fileInfo.Mode()&os.ModeSymlink == 0 {
+			return current, chain, nil
+		}
+
+		target, err := os.Readlink(current)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read symlink target: %w", err)
+		}
+		// A relative target is relative to the link's own directory, not
+		// to the working directory. That directory may itself be reached
+		// through a symlink, in which case ".." leads out of where it
+		// really is rather than out of the path that named it.
+		if !filepath.IsAbs(target) {
+			dir := filepath.Dir(current)
+			if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+				dir = resolved
+			}
+			target = filepath.Join(dir, target)
+		}
+		target = filepath.Clean(target)
+
+		if visited[target] {
+			return "", nil, fmt.Errorf("%w: %s", ErrLoop, strings.Join(append(chain, target), " -> "))
+		}
+		if len(chain) > MaxChainLength {
+			return "", nil, fmt.Errorf("%w: more than %d links from %s", ErrLoop, MaxChainLength, path)
+		}
+		visited[target] = true
+		chain = append(chain, target)
+		current = target
+	}
+}
+
 // PromptAction prompts the user to choose how to handle a symlink.
 // Returns the chosen action.
-func PromptAction(symlinkPath, targetPath string) SymlinkAction {
-	fmt.Printf("\nNote: %s is a symlink to %s\n\n", symlinkPath, targetPath)
+func PromptAction(info *Info) SymlinkAction {
+	symlinkPath, targetPath := info.Path, info.Target
+	if len(info.Chain) > 2 {
+		fmt.Printf("\nNote: %s is a chain of symlinks:\n  %s\n\n", symlinkPath, strings.Join(info.Chain, "\n  -> "))
+	} else {
+		fmt.Printf("\nNote: %s is a symlink to %s\n\n", symlinkPath, targetPath)
+	}
 	fmt.Println("How would you like to proceed?")
 	fmt.Printf("  [1] Replace the symlink target (%s)\n", targetPath)
 	fmt.Printf("  [2] Replace the symlink itself (%s)\n", symlinkPath)
@@ -87,7 +226,7 @@ func PromptAction(symlinkPath, targetPath string) SymlinkAction {
 
 // ErrorNonInteractive returns an error for when a symlink is encountered in non-interactive mode.
 func ErrorNonInteractive(symlinkPath, targetPath string) error {
-	return fmt.Errorf("%s is a symlink to %s\n       Cannot proceed in non-interactive mode.\n       Run without --yes to choose how to handle symlinks", symlinkPath, targetPath)
+	return fmt.Errorf("%s is a symlink to %s\n       Cannot proceed in non-interactive mode.\n       Run without --yes to choose how to handle symlinks, or pass --symlink=target|link|skip", symlinkPath, targetPath)
 }
 
 // ResolveTarget resolves the effective path to use based on the symlink action.
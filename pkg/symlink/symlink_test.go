@@ -1,12 +1,24 @@
 package symlink
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 )
 
+// resolvedTempDir returns a temporary directory without symlinks in its
+// path, so that relative links resolve to paths under it as written.
+func resolvedTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to resolve temp dir: %v", err)
+	}
+	return dir
+}
+
 func TestCheckRegularFile(t *testing.T) {
 	// Create a temp file.
 	tmpDir, err := os.MkdirTemp("", "symlink-test-*")
@@ -69,6 +81,130 @@ func TestCheckSymlink(t *testing.T) {
 	}
 }
 
+func TestCheckRelativeSymlink(t *testing.T) {
+	tmpDir := resolvedTempDir(t)
+
+	// The link lives in bin/ and points at ../opt/tool/tool, which only
+	// exists relative to the link's directory, not the working directory.
+	targetPath := filepath.Join(tmpDir, "opt", "tool", "tool")
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		t.Fatalf("failed to create target directory: %v", err)
+	}
+	if err := os.WriteFile(targetPath, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create target file: %v", err)
+	}
+	symlinkPath := filepath.Join(tmpDir, "bin", "tool")
+	if err := os.MkdirAll(filepath.Dir(symlinkPath), 0755); err != nil {
+		t.Fatalf("failed to create bin directory: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..", "opt", "tool", "tool"), symlinkPath); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	info, err := Check(symlinkPath)
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if info.Target != targetPath {
+		t.Errorf("expected Target to be %q, got %q", targetPath, info.Target)
+	}
+}
+
+func TestCheckRelativeSymlinkInLinkedDirectory(t *testing.T) {
+	tmpDir := resolvedTempDir(t)
+
+	// bin is a link to real/bin, where tool points at ../opt/tool. That is
+	// real/opt/tool, not the opt/tool that bin/../opt/tool would suggest.
+	targetPath := filepath.Join(tmpDir, "real", "opt", "tool")
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		t.Fatalf("failed to create target directory: %v", err)
+	}
+	if err := os.WriteFile(targetPath, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create target file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "real", "bin"), 0755); err != nil {
+		t.Fatalf("failed to create bin directory: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..", "opt", "tool"), filepath.Join(tmpDir, "real", "bin", "tool")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("real", "bin"), filepath.Join(tmpDir, "bin")); err != nil {
+		t.Fatalf("failed to create directory symlink: %v", err)
+	}
+
+	info, err := Check(filepath.Join(tmpDir, "bin", "tool"))
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if info.Target != targetPath {
+		t.Errorf("expected Target to be %q, got %q", targetPath, info.Target)
+	}
+}
+
+func TestCheckSymlinkChain(t *testing.T) {
+	tmpDir := resolvedTempDir(t)
+
+	// link1 -> link2 -> (relative) target
+	targetPath := filepath.Join(tmpDir, "target")
+	if err := os.WriteFile(targetPath, []byte("test"), 0644); err != nil {
+		t.Fatalf("failed to create target file: %v", err)
+	}
+	link2 := filepath.Join(tmpDir, "link2")
+	if err := os.Symlink("target", link2); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	link1 := filepath.Join(tmpDir, "link1")
+	if err := os.Symlink(link2, link1); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	info, err := Check(link1)
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if info.Target != targetPath {
+		t.Errorf("expected Target to be %q, got %q", targetPath, info.Target)
+	}
+	want := []string{link1, link2, targetPath}
+	if strings.Join(info.Chain, " ") != strings.Join(want, " ") {
+		t.Errorf("expected Chain %v, got %v", want, info.Chain)
+	}
+}
+
+func TestCheckSymlinkLoop(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	a := filepath.Join(tmpDir, "a")
+	b := filepath.Join(tmpDir, "b")
+	if err := os.Symlink("b", a); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if err := os.Symlink("a", b); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	if _, err := Check(a); !errors.Is(err, ErrLoop) {
+		t.Errorf("expected ErrLoop, got %v", err)
+	}
+}
+
+func TestCheckDanglingSymlink(t *testing.T) {
+	tmpDir := resolvedTempDir(t)
+
+	symlinkPath := filepath.Join(tmpDir, "link")
+	if err := os.Symlink("missing", symlinkPath); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	info, err := Check(symlinkPath)
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if want := filepath.Join(tmpDir, "missing"); info.Target != want {
+		t.Errorf("expected Target to be %q, got %q", want, info.Target)
+	}
+}
+
 func TestCheckNonExistent(t *testing.T) {
 	info, err := Check("/non/existent/path")
 	if err != nil {
@@ -112,3 +248,55 @@ func TestErrorNonInteractive(t *testing.T) {
 		t.Errorf("error message should mention 'non-interactive': %s", errStr)
 	}
 }
+
+func TestParsePolicy(t *testing.T) {
+	for _, action := range []SymlinkAction{ActionReplaceTarget, ActionReplaceSymlink, ActionSkip} {
+		got, err := ParsePolicy(PolicyName(action))
+		if err != nil {
+			t.Fatalf("ParsePolicy(%q) returned error: %v", PolicyName(action), err)
+		}
+		if got != action {
+			t.Errorf("ParsePolicy(%q): expected %v, got %v", PolicyName(action), action, got)
+		}
+	}
+	if _, err := ParsePolicy("both"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestDecide(t *testing.T) {
+	info := &Info{IsSymlink: true, Path: "/usr/local/bin/myapp", Target: "/opt/myapp/myapp"}
+
+	tests := []struct {
+		name                       string
+		flag, recorded, configured string
+		want                       SymlinkAction
+		wantErr                    bool
+	}{
+		{name: "flag wins", flag: PolicySkip, recorded: PolicyLink, configured: PolicyTarget, want: ActionSkip},
+		{name: "recorded beats config", recorded: PolicyLink, configured: PolicyTarget, want: ActionReplaceSymlink},
+		{name: "config default", configured: PolicyTarget, want: ActionReplaceTarget},
+		{name: "nothing decided", wantErr: true},
+		{name: "bad flag", flag: "sideways", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Non-interactive, so an undecided symlink is an error rather
+			// than a prompt.
+			decision, err := Decide(info, tt.flag, tt.recorded, tt.configured, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", decision)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decide returned error: %v", err)
+			}
+			if decision.Action != tt.want || decision.Prompted {
+				t.Errorf("expected %v without prompting, got %+v", tt.want, decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+// Package sync brings the managed executables in line with a manifest.
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/install"
+	"github.com/sfkleach/execman/pkg/manifest"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/remove"
+	"github.com/sfkleach/execman/pkg/semver"
+	"github.com/spf13/cobra"
+)
+
+// Options for the sync command.
+type Options struct {
+	ManifestPath string
+	DryRun       bool
+	Prune        bool
+	Yes          bool
+}
+
+// Action is what sync does to bring one executable in line.
+type Action string
+
+// Sync actions.
+const (
+	ActionInstall   Action = "install"
+	ActionReinstall Action = "reinstall" // The declared version's file is missing.
+	ActionUpgrade   Action = "upgrade"
+	ActionDowngrade Action = "downgrade"
+	ActionChange    Action = "change" // The versions cannot be ordered.
+	ActionRemove    Action = "remove"
+	ActionKeep      Action = "keep"
+	ActionConflict  Action = "conflict"
+)
+
+// symbols mark each action in the printed plan.
+var symbols = map[Action]string{
+	ActionInstall:   "+",
+	ActionReinstall: "+",
+	ActionUpgrade:   "^",
+	ActionDowngrade: "v",
+	ActionChange:    "~",
+	ActionRemove:    "-",
+	ActionKeep:      "=",
+	ActionConflict:  "!",
+}
+
+// Step is one entry of a sync plan.
+type Step struct {
+	Action Action
+	Name   string
+	Tool   *manifest.Tool // Nil for removals.
+	From   string         // Installed version, if any.
+	To     string         // Declared version to install, if any.
+	Dir    string         // Install directory.
+	Layout string         // Layout of the existing install, if any.
+	Note   string
+}
+
+// Changes reports whether carrying out the step changes anything.
+func (s *Step) Changes() bool {
+	return s.Action != ActionKeep && s.Action != ActionConflict
+}
+
+// NewSyncCommand creates the sync command.
+func NewSyncCommand() *cobra.Command {
+	var dryRun bool
+	var prune bool
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "sync [manifest]",
+		Short: "Install, update and remove executables to match a manifest",
+		Long: `Bring the managed executables in line with a manifest (execman.json by default):
+install tools that are missing, and update or downgrade tools to the declared versions.
+With --prune, managed executables that the manifest does not list are removed.
+Use --dry-run to see the plan without changing anything.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := Options{
+				ManifestPath: manifest.DefaultPath,
+				DryRun:       dryRun,
+				Prune:        prune,
+				Yes:          yes,
+			}
+			if len(args) > 0 {
+				opts.ManifestPath = args[0]
+			}
+			return Run(opts)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Show the plan without changing anything")
+	cmd.Flags().BoolVar(&prune, "prune", false, "Remove managed executables that are not in the manifest")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+// Run executes the sync command.
+func Run(opts Options) error {
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	m, err := manifest.Load(opts.ManifestPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Resolving versions for %s...\n", opts.ManifestPath)
+	steps, unlisted := Plan(m, reg, cfg.DefaultInstallDir, opts.Prune)
+
+	changes := 0
+	conflicts := 0
+	fmt.Printf("\nPlan:\n")
+	for i := range steps {
+		printStep(&steps[i])
+		if steps[i].Changes() {
+			changes++
+		}
+		if steps[i].Action == ActionConflict {
+			conflicts++
+		}
+	}
+	if len(unlisted) > 0 {
+		fmt.Printf("\nNot in the manifest (use --prune to remove): %s\n", strings.Join(unlisted, ", "))
+	}
+
+	if changes == 0 {
+		fmt.Println("\nNothing to do.")
+		return conflictError(conflicts)
+	}
+	if opts.DryRun {
+		fmt.Printf("\nDry run: %d change(s) not made.\n", changes)
+		return conflictError(conflicts)
+	}
+
+	if !opts.Yes {
+		fmt.Printf("\nMake %d change(s)? [y/N]: ", changes)
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.ToLower(strings.TrimSpace(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Sync cancelled.")
+			return nil
+		}
+	}
+
+	failed := 0
+	for i := range steps {
+		step := &steps[i]
+		if !step.Changes() {
+			continue
+		}
+		fmt.Printf("\n%s %s...\n", strings.ToUpper(string(step.Action[:1]))+string(step.Action[1:]), step.Name)
+		if err := apply(step, m); err != nil {
+			fmt.Printf("Failed to %s %s: %v\n", step.Action, step.Name, err)
+			failed++
+		}
+	}
+
+	fmt.Printf("\n%d change(s) made, %d failed.\n", changes-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d changes failed", failed, changes)
+	}
+	return conflictError(conflicts)
+}
+
+// conflictError reports tools that sync could not reconcile.
+func conflictError(conflicts int) error {
+	if conflicts > 0 {
+		return fmt.Errorf("%d tool(s) could not be synced", conflicts)
+	}
+	return nil
+}
+
+// Plan works out the steps that bring reg in line with m. Executables in
+// reg that m does not list become removals if prune is set, and are
+// otherwise returned by name.
+func Plan(m *manifest.Manifest, reg *registry.Registry, defaultDir string, prune bool) ([]Step, []string) {
+	var steps []Step
+	listed := make(map[string]bool)
+
+	for i := range m.Tools {
+		tool := &m.Tools[i]
+		listed[tool.Name()] = true
+		steps = append(steps, planTool(tool, m, reg, defaultDir))
+	}
+
+	var unlisted []string
+	names := reg.List()
+	sort.Strings(names)
+	for _, name := range names {
+		if listed[name] {
+			continue
+		}
+		if !prune {
+			unlisted = append(unlisted, name)
+			continue
+		}
+		exec, _ := reg.Get(name)
+		steps = append(steps, Step{Action: ActionRemove, Name: name, From: exec.Version, Dir: filepath.Dir(exec.Path)})
+	}
+
+	return steps, unlisted
+}
+
+// planTool works out the step for one tool in the manifest.
+func planTool(tool *manifest.Tool, m *manifest.Manifest, reg *registry.Registry, defaultDir string) Step {
+	step := Step{Name: tool.Name(), Tool: tool}
+
+	declaredDir := tool.InstallDir
+	if declaredDir == "" {
+		declaredDir = m.InstallDir
+	}
+
+	exec, found := reg.Get(tool.Name())
+	if found {
+		owner, repo, _, err := github.ParseSource(exec.Source)
+		if err != nil || !strings.EqualFold(owner+"/"+repo, tool.Owner()+"/"+tool.Name()) {
+			step.Action = ActionConflict
+			step.Note = fmt.Sprintf("already managed from %s", exec.Source)
+			return step
+		}
+		step.From = exec.Version
+		// Keep the existing layout rather than switching to the configured one.
+		step.Layout = exec.Layout
+		if step.Layout == "" {
+			step.Layout = registry.LayoutCopy
+		}
+		step.Dir = filepath.Dir(exec.Path)
+		if declaredDir != "" && filepath.Clean(declaredDir) != step.Dir {
+			step.Note = fmt.Sprintf("installed in %s, not %s; remove it and sync again to move it", step.Dir, declaredDir)
+		}
+	} else {
+		step.Dir = declaredDir
+		if step.Dir == "" {
+			step.Dir = defaultDir
+		}
+	}
+
+	to, err := resolve(tool, m.IncludePrereleases)
+	if err != nil {
+		step.Action = ActionConflict
+		step.Note = err.Error()
+		return step
+	}
+	step.To = to
+
+	switch {
+	case !found:
+		step.Action = ActionInstall
+	case step.From == step.To:
+		step.Action = ActionKeep
+		if _, err := os.Stat(exec.Path); err != nil {
+			step.Action = ActionReinstall
+		}
+	default:
+		step.Action = ActionChange
+		from, fromErr := semver.Parse(step.From)
+		target, toErr := semver.Parse(step.To)
+		if fromErr == nil && toErr == nil {
+			if from.Compare(target) < 0 {
+				step.Action = ActionUpgrade
+			} else {
+				step.Action = ActionDowngrade
+			}
+		}
+	}
+	return step
+}
+
+// resolve returns the release tag a tool's declared version stands for: the
+// tag itself if exact, otherwise the highest release among the most recent
+// ones that satisfies the constraint.
+func resolve(tool *manifest.Tool, includePrereleases bool) (string, error) {
+	if tool.Exact() {
+		return tool.Version, nil
+	}
+
+	releases, err := github.ListReleases(tool.Owner(), tool.Name())
+	if err != nil {
+		return "", err
+	}
+
+	var best *semver.Version
+	bestTag := ""
+	for _, release := range releases {
+		if release.Prerelease && !includePrereleases {
+			continue
+		}
+		v, err := semver.Parse(release.TagName)
+		if err != nil || !tool.Constraint().Check(v) {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			best, bestTag = v, release.TagName
+		}
+	}
+	if best == nil {
+		if tool.Version == "" {
+			return "", fmt.Errorf("no releases found for %s", tool.URL())
+		}
+		return "", fmt.Errorf("no recent release of %s matches %q", tool.URL(), tool.Version)
+	}
+	return bestTag, nil
+}
+
+// apply carries out one step of the plan.
+func apply(step *Step, m *manifest.Manifest) error {
+	if step.Action == ActionRemove {
+		return remove.Remove(remove.Options{Name: step.Name, Yes: true})
+	}
+	return install.Run(install.Options{
+		Source:             step.Tool.URL() + "@" + step.To,
+		Into:               step.Dir,
+		Yes:                true,
+		IncludePrereleases: m.IncludePrereleases,
+		Layout:             step.Layout,
+	})
+}
+
+// printStep prints one line of the plan, with its note if any.
+func printStep(step *Step) {
+	var detail string
+	switch step.Action {
+	case ActionInstall:
+		detail = fmt.Sprintf("install %s into %s", step.To, step.Dir)
+	case ActionReinstall:
+		detail = fmt.Sprintf("reinstall %s into %s (file missing)", step.To, step.Dir)
+	case ActionUpgrade, ActionDowngrade, ActionChange:
+		detail = fmt.Sprintf("%s %s -> %s", step.Action, step.From, step.To)
+	case ActionRemove:
+		detail = fmt.Sprintf("remove %s from %s", step.From, step.Dir)
+	case ActionKeep:
+		detail = fmt.Sprintf("keep %s", step.From)
+	case ActionConflict:
+		detail = "cannot sync: " + step.Note
+	}
+	fmt.Printf("  %s %-20s %s\n", symbols[step.Action], step.Name, detail)
+	if step.Note != "" && step.Action != ActionConflict {
+		fmt.Printf("    Note: %s\n", step.Note)
+	}
+}
@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/manifest"
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+func TestPlan(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/alpha/releases", "/repos/acme/beta/releases", "/repos/acme/gamma/releases", "/repos/acme/eta/releases":
+			_, _ = w.Write([]byte(`[
+				{"tag_name": "v2.0.0-rc.1", "prerelease": true},
+				{"tag_name": "v1.5.0"},
+				{"tag_name": "v1.4.2"},
+				{"tag_name": "v0.9.0"}
+			]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	savedBaseURL := github.APIBaseURL
+	github.APIBaseURL = server.URL
+	defer func() { github.APIBaseURL = savedBaseURL }()
+
+	m, err := manifest.Parse([]byte(`{"tools": [
+		{"source": "github.com/acme/alpha", "version": "~1.4"},
+		{"source": "github.com/acme/beta", "version": "^1"},
+		{"source": "github.com/acme/gamma"},
+		{"source": "github.com/acme/delta", "version": "v3.0.0"},
+		{"source": "github.com/acme/epsilon", "version": "v1.0.0"},
+		{"source": "github.com/acme/zeta", "version": "^5"},
+		{"source": "github.com/acme/eta", "version": "^1"}
+	]}`))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	// Every installed file exists except eta's.
+	bin := t.TempDir()
+	for _, name := range []string{"alpha", "beta", "gamma", "epsilon", "stray"} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(name), 0755); err != nil {
+			t.Fatalf("WriteFile returned error: %v", err)
+		}
+	}
+	reg := &registry.Registry{Executables: map[string]*registry.Executable{
+		"alpha":   {Source: "https://github.com/acme/alpha", Version: "v1.5.0", Path: filepath.Join(bin, "alpha")},
+		"beta":    {Source: "https://github.com/acme/beta", Version: "v1.4.2", Path: filepath.Join(bin, "beta")},
+		"gamma":   {Source: "https://github.com/acme/gamma", Version: "v1.5.0", Path: filepath.Join(bin, "gamma")},
+		"epsilon": {Source: "https://github.com/other/epsilon", Version: "v1.0.0", Path: filepath.Join(bin, "epsilon")},
+		"stray":   {Source: "https://github.com/acme/stray", Version: "v1.0.0", Path: filepath.Join(bin, "stray")},
+		"eta":     {Source: "https://github.com/acme/eta", Version: "v1.5.0", Path: filepath.Join(bin, "eta")},
+	}}
+
+	want := map[string]struct {
+		action Action
+		to     string
+	}{
+		"alpha":   {action: ActionDowngrade, to: "v1.4.2"},
+		"beta":    {action: ActionUpgrade, to: "v1.5.0"},
+		"gamma":   {action: ActionKeep, to: "v1.5.0"},
+		"delta":   {action: ActionInstall, to: "v3.0.0"},
+		"epsilon": {action: ActionConflict},
+		"zeta":    {action: ActionConflict},
+		"eta":     {action: ActionReinstall, to: "v1.5.0"},
+	}
+
+	steps, unlisted := Plan(m, reg, "/default/bin", false)
+	if len(steps) != len(want) {
+		t.Fatalf("expected %d steps, got %+v", len(want), steps)
+	}
+	for _, step := range steps {
+		w := want[step.Name]
+		if step.Action != w.action || step.To != w.to {
+			t.Errorf("%s: expected %s %q, got %s %q (%s)", step.Name, w.action, w.to, step.Action, step.To, step.Note)
+		}
+	}
+	if steps[3].Dir != "/default/bin" {
+		t.Errorf("expected new tool to go to the default directory, got %q", steps[3].Dir)
+	}
+	if len(unlisted) != 1 || unlisted[0] != "stray" {
+		t.Errorf("expected stray to be unlisted, got %v", unlisted)
+	}
+
+	steps, unlisted = Plan(m, reg, "/default/bin", true)
+	last := steps[len(steps)-1]
+	if last.Action != ActionRemove || last.Name != "stray" || len(unlisted) != 0 {
+		t.Errorf("expected stray to be removed with prune, got %+v (unlisted %v)", last, unlisted)
+	}
+}
@@ -2,6 +2,7 @@ package update
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,9 +11,16 @@ import (
 	"time"
 
 	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/atomicfile"
 	"github.com/sfkleach/execman/pkg/config"
 	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/gobuild"
+	"github.com/sfkleach/execman/pkg/hook"
+	"github.com/sfkleach/execman/pkg/install"
+	"github.com/sfkleach/execman/pkg/policy"
 	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/source"
+	"github.com/sfkleach/execman/pkg/store"
 	"github.com/sfkleach/execman/pkg/symlink"
 	"github.com/spf13/cobra"
 )
@@ -23,13 +31,26 @@ type Options struct {
 	All                bool
 	Yes                bool
 	IncludePrereleases bool
+	AllowMoved         bool
+	Limits             archive.Limits
+	PreActivationHook  string
+	StoreDir           string
+	KeepVersions       int
+	// Symlink is the --symlink policy; DefaultSymlink is the configured one.
+	Symlink        string
+	DefaultSymlink string
 }
 
+// errSkipped reports that an executable was deliberately left alone.
+var errSkipped = errors.New("skipped")
+
 // NewUpdateCommand creates the update command.
 func NewUpdateCommand() *cobra.Command {
 	var all bool
 	var yes bool
 	var includePrereleases bool
+	var allowMoved bool
+	var symlinkPolicy string
 
 	cmd := &cobra.Command{
 		Use:   "update [executable]",
@@ -55,6 +76,8 @@ func NewUpdateCommand() *cobra.Command {
 				All:                all,
 				Yes:                yes,
 				IncludePrereleases: includePrereleases,
+				AllowMoved:         allowMoved,
+				Symlink:            symlinkPolicy,
 			}
 			return Run(opts)
 		},
@@ -63,6 +86,8 @@ func NewUpdateCommand() *cobra.Command {
 	cmd.Flags().BoolVarP(&all, "all", "a", false, "Update all managed executables")
 	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip all confirmation prompts")
 	cmd.Flags().BoolVar(&includePrereleases, "include-prereleases", false, "Allow updating to prerelease versions")
+	cmd.Flags().BoolVar(&allowMoved, "allow-moved", false, "Accept upstream repositories that were renamed or transferred")
+	cmd.Flags().StringVar(&symlinkPolicy, "symlink", "", "How to handle executables that are symlinks: target, link or skip")
 
 	return cmd
 }
@@ -83,12 +108,29 @@ func Run(opts Options) error {
 	if !opts.IncludePrereleases {
 		opts.IncludePrereleases = cfg.IncludePrereleases
 	}
+	opts.Limits = cfg.ExtractLimits()
+	opts.PreActivationHook = cfg.PreActivationHook
+	opts.StoreDir = cfg.StoreDir
+	opts.KeepVersions = cfg.KeepVersions
+	opts.DefaultSymlink = cfg.SymlinkPolicy
+
+	// Reject a bad policy up front rather than once per symlink.
+	for _, name := range []string{opts.Symlink, opts.DefaultSymlink} {
+		if name != "" {
+			if _, err := symlink.ParsePolicy(name); err != nil {
+				return err
+			}
+		}
+	}
 
 	if opts.All {
 		return updateAll(reg, opts)
 	}
 
 	_, err = updateOne(reg, opts)
+	if errors.Is(err, errSkipped) {
+		return nil
+	}
 	return err
 }
 
@@ -102,12 +144,15 @@ func updateAll(reg *registry.Registry, opts Options) error {
 	updatedCount := 0
 	upToDateCount := 0
 	failCount := 0
+	skippedCount := 0
 
 	for _, name := range names {
 		fmt.Printf("\nUpdating %s...\n", name)
 		opts.Name = name
 		updated, err := updateOne(reg, opts)
-		if err != nil {
+		if errors.Is(err, errSkipped) {
+			skippedCount++
+		} else if err != nil {
 			fmt.Printf("Failed to update %s: %v\n", name, err)
 			failCount++
 		} else if updated {
@@ -117,7 +162,11 @@ func updateAll(reg *registry.Registry, opts Options) error {
 		}
 	}
 
-	fmt.Printf("\n%d updated, %d already up to date, %d failed.\n", updatedCount, upToDateCount, failCount)
+	if skippedCount > 0 {
+		fmt.Printf("\n%d updated, %d already up to date, %d skipped, %d failed.\n", updatedCount, upToDateCount, skippedCount, failCount)
+	} else {
+		fmt.Printf("\n%d updated, %d already up to date, %d failed.\n", updatedCount, upToDateCount, failCount)
+	}
 	return nil
 }
 
@@ -128,6 +177,11 @@ func updateOne(reg *registry.Registry, opts Options) (bool, error) {
 		return false, fmt.Errorf("executable %q is not managed by execman", opts.Name)
 	}
 
+	// The policy may have changed since installation, so check it again.
+	if err := policy.Enforce(exec.Source); err != nil {
+		return false, err
+	}
+
 	// Check if executable file exists and if it's a symlink.
 	executableMissing := false
 	var symlinkInfo *symlink.Info
@@ -136,42 +190,92 @@ func updateOne(reg *registry.Registry, opts Options) (bool, error) {
 
 	if _, err := os.Stat(exec.Path); os.IsNotExist(err) {
 		executableMissing = true
-	} else {
-		// Check for symlink.
+	} else if !exec.Versioned() {
+		// Check for symlink. A versioned install's symlink is execman's own,
+		// so it is switched without asking.
 		symlinkInfo, err = symlink.Check(exec.Path)
 		if err != nil {
 			return false, fmt.Errorf("failed to check path: %w", err)
 		}
 
 		if symlinkInfo.IsSymlink {
-			if opts.Yes {
-				// Non-interactive mode with symlink - error out.
-				return false, symlink.ErrorNonInteractive(symlinkInfo.Path, symlinkInfo.Target)
+			decision, err := symlink.Decide(symlinkInfo, opts.Symlink, exec.SymlinkPolicy, opts.DefaultSymlink, !opts.Yes)
+			if err != nil {
+				return false, err
 			}
-			// Interactive mode - ask user.
-			symlinkAction = symlink.PromptAction(symlinkInfo.Path, symlinkInfo.Target)
-			if symlinkAction == symlink.ActionCancel {
+			symlinkAction = decision.Action
+			switch symlinkAction {
+			case symlink.ActionCancel:
 				fmt.Println("Update cancelled.")
 				return false, nil
+			case symlink.ActionSkip:
+				fmt.Printf("Skipping %s: %s is a symlink.\n", opts.Name, symlinkInfo.Path)
+				return false, errSkipped
+			}
+			if decision.Prompted {
+				rememberSymlinkPolicy(opts.Name, symlinkAction)
+				exec.SymlinkPolicy = symlink.PolicyName(symlinkAction)
 			}
 			effectivePath = symlink.ResolveTarget(symlinkInfo, symlinkAction)
 		}
 	}
 
-	// Parse source.
-	owner, repo, _, err := github.ParseSource(exec.Source)
+	// Sources other than GitHub repositories have a provider.
+	provider, err := source.ForExecutable(exec)
 	if err != nil {
 		return false, err
 	}
 
-	// Fetch latest release.
-	fmt.Printf("Checking for updates from %s/%s...\n", owner, repo)
-	release, err := github.GetLatestRelease(owner, repo, opts.IncludePrereleases)
-	if err != nil {
-		return false, err
-	}
+	var owner, repo, latestVersion string
+	var release *github.Release
+	if provider != nil {
+		fmt.Printf("Checking for updates from %s...\n", exec.Source)
+		latestVersion, err = provider.Latest(opts.IncludePrereleases)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		// Parse source.
+		owner, repo, _, err = github.ParseSource(exec.Source)
+		if err != nil {
+			return false, err
+		}
+
+		// Make sure the upstream repository is still the one we installed from.
+		repository, err := github.GetRepository(owner, repo)
+		if err != nil {
+			return false, err
+		}
+		recordedSource, recordedID := exec.Source, exec.RepoID
+		if repository.Changed(owner, repo, exec.RepoID) {
+			confirmed, err := install.ConfirmMoved(owner, repo, exec.RepoID, repository, "updating "+opts.Name, opts.Yes, opts.AllowMoved)
+			if err != nil {
+				return false, err
+			}
+			if !confirmed {
+				fmt.Println("Update cancelled.")
+				return false, nil
+			}
+			newSource := github.ToURL(repository.Owner(), repository.Name())
+			if err := policy.Enforce(newSource); err != nil {
+				return false, err
+			}
+			owner, repo = repository.Owner(), repository.Name()
+			exec.Source = newSource
+		}
+		exec.RepoID = repository.ID
+		if exec.Source != recordedSource || exec.RepoID != recordedID {
+			rememberRepository(opts.Name, exec.Source, exec.RepoID)
+		}
 
-	latestVersion := release.TagName
+		// Fetch latest release.
+		fmt.Printf("Checking for updates from %s/%s...\n", owner, repo)
+		release, err = github.GetLatestRelease(owner, repo, opts.IncludePrereleases)
+		if err != nil {
+			return false, err
+		}
+		latestVersion = release.TagName
+	}
 
 	// Handle missing executable.
 	if executableMissing {
@@ -199,9 +303,11 @@ func updateOne(reg *registry.Registry, opts Options) (bool, error) {
 				switch response {
 				case "r", "recorded":
 					// Use recorded version - need to fetch that specific release.
-					release, err = github.GetRelease(owner, repo, exec.Version)
-					if err != nil {
-						return false, fmt.Errorf("failed to fetch recorded version %s: %w", exec.Version, err)
+					if provider == nil {
+						release, err = github.GetRelease(owner, repo, exec.Version)
+						if err != nil {
+							return false, fmt.Errorf("failed to fetch recorded version %s: %w", exec.Version, err)
+						}
 					}
 					latestVersion = exec.Version
 				case "l", "latest":
@@ -238,22 +344,6 @@ func updateOne(reg *registry.Registry, opts Options) (bool, error) {
 		}
 	}
 
-	// Ask about backup (only if file exists).
-	createBackup := false
-	if !executableMissing && !opts.Yes {
-		fmt.Print("Create backup of current executable? [y/N]: ")
-		reader := bufio.NewReader(os.Stdin)
-		response, _ := reader.ReadString('\n')
-		response = strings.ToLower(strings.TrimSpace(response))
-		createBackup = response == "y" || response == "yes"
-	}
-
-	// Find matching asset.
-	asset, err := github.FindAsset(release.Assets, runtime.GOOS, runtime.GOARCH)
-	if err != nil {
-		return false, err
-	}
-
 	// Create temporary directory for download.
 	tmpDir, err := os.MkdirTemp("", "execman-update-*")
 	if err != nil {
@@ -261,17 +351,63 @@ func updateOne(reg *registry.Registry, opts Options) (bool, error) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// Download asset.
-	archivePath := filepath.Join(tmpDir, asset.Name)
-	fmt.Printf("Downloading %s...\n", asset.Name)
-	if err := github.DownloadAsset(asset, archivePath); err != nil {
+	var asset *github.Asset
+	var archivePath string
+	var provenance *install.Provenance
+	var built *gobuild.Result
+	if provider == nil {
+		asset, err = github.FindAsset(release.Assets, runtime.GOOS, runtime.GOARCH)
+	}
+	switch {
+	case provider != nil:
+		download, err := provider.Download(latestVersion, runtime.GOOS, runtime.GOARCH, tmpDir)
+		if err != nil {
+			return false, err
+		}
+		asset = &github.Asset{Name: download.AssetName, BrowserDownloadURL: download.AssetURL}
+		archivePath = download.Path
+		provenance = &install.Provenance{
+			ArchiveChecksum: download.ArchiveChecksum,
+			ChecksumSource:  download.ChecksumSource,
+			DownloadedAt:    download.DownloadedAt,
+		}
+	case exec.BuiltFrom != "" && errors.Is(err, github.ErrNoMatchingAsset):
+		// A tool that was built from source is built again while its
+		// releases still have no asset for the platform.
+		fmt.Printf("No asset for %s/%s; building from source.\n", runtime.GOOS, runtime.GOARCH)
+		built, err = gobuild.Build(gobuild.Candidates(owner, repo, latestVersion, exec.BuiltFrom), latestVersion, tmpDir)
+		if err != nil {
+			return false, err
+		}
+		asset = &github.Asset{}
+		archivePath = built.Path
+		provenance = &install.Provenance{}
+	case err != nil:
 		return false, err
+	default:
+		// Download asset.
+		archivePath = filepath.Join(tmpDir, asset.Name)
+		fmt.Printf("Downloading %s...\n", asset.Name)
+		if err := github.DownloadAsset(asset, archivePath); err != nil {
+			return false, err
+		}
+
+		// Verify the download against the release's checksums file, if any.
+		provenance, err = install.VerifyDownload(release, asset, archivePath, tmpDir)
+		if err != nil {
+			return false, err
+		}
 	}
 
-	// Extract binary to temp location.
-	binaryPath := filepath.Join(tmpDir, "binary")
+	// Extract binary to temp location. It is named after the executable, as
+	// install names it, since that is how a zip without permissions is read.
+	stagedDir := filepath.Join(tmpDir, "staged")
+	if err := os.Mkdir(stagedDir, 0700); err != nil {
+		return false, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	binaryPath := filepath.Join(stagedDir, opts.Name)
 	fmt.Println("Extracting...")
-	if err := archive.ExtractBinary(archivePath, binaryPath); err != nil {
+	if err := archive.ExtractBinaryWithLimits(archivePath, binaryPath, opts.Limits); err != nil {
 		return false, err
 	}
 
@@ -281,35 +417,52 @@ func updateOne(reg *registry.Registry, opts Options) (bool, error) {
 		return false, fmt.Errorf("failed to calculate checksum: %w", err)
 	}
 
+	// Let the user's scanner veto the binary before it is activated.
+	hookResult, err := hook.Run(opts.PreActivationHook, binaryPath)
+	if err != nil {
+		return false, err
+	}
+
 	// Check permissions on target.
 	targetDir := filepath.Dir(effectivePath)
 	if err := os.MkdirAll(targetDir, 0750); err != nil {
 		return false, fmt.Errorf("failed to create target directory: %w", err)
 	}
 
-	// Create backup if requested.
-	if createBackup {
-		backupPath := effectivePath + ".backup"
-		fmt.Printf("Creating backup at %s...\n", backupPath)
-		if err := copyFile(effectivePath, backupPath); err != nil {
-			return false, fmt.Errorf("failed to create backup: %w", err)
-		}
-	}
-
-	// Replace executable.
+	var previous *registry.Executable
+	var replacement *atomicfile.Replacement
 	fmt.Println("Installing...")
-	if err := os.Remove(effectivePath); err != nil && !os.IsNotExist(err) {
-		return false, fmt.Errorf("failed to remove old executable: %w", err)
-	}
-
-	if err := copyFile(binaryPath, effectivePath); err != nil {
-		return false, fmt.Errorf("failed to install new executable: %w", err)
-	}
+	if exec.Versioned() {
+		// The current version is already in the store, so switching the
+		// link is all it takes, and the old version stays for rollback.
+		if !executableMissing {
+			previous = exec.Snapshot(store.Path(opts.StoreDir, opts.Name, exec.Version))
+		}
+		stored, err := store.Save(opts.StoreDir, opts.Name, latestVersion, binaryPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to install new executable: %w", err)
+		}
+		replacement, err = atomicfile.ReplaceSymlink(stored, exec.Path)
+		if err != nil {
+			return false, fmt.Errorf("failed to link new executable: %w", err)
+		}
+	} else {
+		// Keep the current version in the store so that it can be rolled back to.
+		if !executableMissing && opts.KeepVersions > 0 {
+			fmt.Printf("Keeping %s %s for rollback...\n", opts.Name, exec.Version)
+			stored, err := store.Save(opts.StoreDir, opts.Name, exec.Version, effectivePath)
+			if err != nil {
+				return false, fmt.Errorf("failed to keep previous version: %w", err)
+			}
+			previous = exec.Snapshot(stored)
+		}
 
-	// Set executable permissions.
-	// #nosec G302 -- Executables need 0755 permissions
-	if err := os.Chmod(effectivePath, 0755); err != nil {
-		return false, fmt.Errorf("failed to set executable permissions: %w", err)
+		// Replace executable atomically, keeping the old one until the
+		// registry records the new version.
+		replacement, err = atomicfile.ReplaceExecutable(binaryPath, effectivePath)
+		if err != nil {
+			return false, fmt.Errorf("failed to install new executable: %w", err)
+		}
 	}
 
 	// Update registry - if we replaced the symlink itself, update the path.
@@ -319,16 +472,48 @@ func updateOne(reg *registry.Registry, opts Options) (bool, error) {
 	exec.Version = latestVersion
 	exec.Checksum = checksum
 	exec.InstalledAt = time.Now()
+	exec.AssetName = asset.Name
+	exec.AssetURL = asset.BrowserDownloadURL
+	exec.ArchiveChecksum = provenance.ArchiveChecksum
+	exec.ChecksumSource = provenance.ChecksumSource
+	exec.DownloadedAt = provenance.DownloadedAt
+	exec.BuiltFrom = ""
+	if built != nil {
+		exec.BuiltFrom = built.Package
+	}
+	exec.Hook = hookResult
+	// A version being reinstalled is current again rather than history. Its
+	// stored copy is the current binary in the versioned layout.
+	var dropped []*registry.Executable
+	if old, ok := exec.RemoveHistory(latestVersion); ok && !exec.Versioned() {
+		dropped = append(dropped, old)
+	}
+	if previous != nil {
+		dropped = append(dropped, exec.PushHistory(previous, opts.KeepVersions)...)
+	}
 
-	reg.Add(opts.Name, exec)
-	if err := reg.Save(); err != nil {
-		return false, fmt.Errorf("failed to update registry: %w", err)
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Add(opts.Name, exec)
+		return nil
+	}); err != nil {
+		if restoreErr := replacement.Restore(); restoreErr != nil {
+			return false, fmt.Errorf("failed to update registry: %w (and failed to restore previous executable: %v)", err, restoreErr)
+		}
+		return false, fmt.Errorf("failed to update registry (previous executable restored): %w", err)
+	}
+	if err := replacement.Commit(); err != nil {
+		fmt.Printf("Warning: failed to remove previous executable: %v\n", err)
+	}
+	for _, old := range dropped {
+		if err := store.Delete(opts.StoreDir, opts.Name, old.Version); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
 	}
 
 	fmt.Printf("\nSuccessfully updated %s to %s\n", opts.Name, latestVersion)
 
-	// Ask about cleanup.
-	if !opts.Yes {
+	// Ask about cleanup. A binary built from source left no archive.
+	if !opts.Yes && built == nil {
 		fmt.Print("Delete download archive? [Y/n]: ")
 		reader := bufio.NewReader(os.Stdin)
 		response, _ := reader.ReadString('\n')
@@ -348,6 +533,38 @@ func updateOne(reg *registry.Registry, opts Options) (bool, error) {
 	return true, nil
 }
 
+// rememberSymlinkPolicy records an interactive symlink choice for the
+// executable, so that later updates, including unattended ones, reuse it.
+func rememberSymlinkPolicy(name string, action symlink.SymlinkAction) {
+	policyName := symlink.PolicyName(action)
+	err := registry.Update(func(r *registry.Registry) error {
+		if exec, ok := r.Get(name); ok {
+			exec.SymlinkPolicy = policyName
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to remember symlink choice: %v\n", err)
+		return
+	}
+	fmt.Printf("Remembered %q for %s; override with --symlink.\n", policyName, name)
+}
+
+// rememberRepository records a confirmed move or a newly learned repository
+// ID straight away, so that they are kept even if no update follows.
+func rememberRepository(name, source string, repoID int64) {
+	err := registry.Update(func(r *registry.Registry) error {
+		if exec, ok := r.Get(name); ok {
+			exec.Source = source
+			exec.RepoID = repoID
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to record the repository for %s: %v\n", name, err)
+	}
+}
+
 // copyFile copies a file from src to dst.
 func copyFile(src, dst string) error {
 	// #nosec G304 -- Reading from controlled temp directory and registry paths
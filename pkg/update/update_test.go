@@ -0,0 +1,163 @@
+package update
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/github"
+	"github.com/sfkleach/execman/pkg/registry"
+)
+
+// fakeGitHub serves a repository, its releases and one release asset, under
+// whichever owner they are asked for. In releases, {server} stands for the
+// server's own URL.
+func fakeGitHub(t *testing.T, repository, releases string, asset []byte) {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/download/asset":
+			_, _ = w.Write(asset)
+		case strings.HasPrefix(r.URL.Path, "/repos/") && strings.HasSuffix(r.URL.Path, "/tool/releases"):
+			_, _ = w.Write(bytes.ReplaceAll([]byte(releases), []byte("{server}"), []byte(server.URL)))
+		case strings.HasPrefix(r.URL.Path, "/repos/") && strings.HasSuffix(r.URL.Path, "/tool"):
+			_, _ = w.Write([]byte(repository))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	savedBaseURL := github.APIBaseURL
+	github.APIBaseURL = server.URL
+	t.Cleanup(func() { github.APIBaseURL = savedBaseURL })
+}
+
+// setupInstalled isolates the config and registry and records tool as
+// installed at version, returning its path.
+func setupInstalled(t *testing.T, exec *registry.Executable) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	exec.Path = filepath.Join(home, "bin", "tool")
+	if err := os.MkdirAll(filepath.Dir(exec.Path), 0755); err != nil {
+		t.Fatalf("failed to create bin directory: %v", err)
+	}
+	if err := os.WriteFile(exec.Path, []byte("#!/bin/sh\necho old\n"), 0755); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Add("tool", exec)
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to save registry: %v", err)
+	}
+	return exec.Path
+}
+
+// loadEntry returns tool's registry entry.
+func loadEntry(t *testing.T) *registry.Executable {
+	t.Helper()
+	reg, err := registry.Load()
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+	exec, found := reg.Get("tool")
+	if !found {
+		t.Fatal("tool is not in the registry")
+	}
+	return exec
+}
+
+func TestUpdateZipWithoutPermissions(t *testing.T) {
+	// A zip made on Windows records no permissions, so the binary is found
+	// by its name alone.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"README.md": "read me\n", "tool": "#!/bin/sh\necho new\n"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+
+	assetName := "tool_" + runtime.GOOS + "_" + runtime.GOARCH + ".zip"
+	fakeGitHub(t, `{"id": 7, "full_name": "acme/tool"}`,
+		`[{"tag_name": "v2.0.0", "assets": [{"name": "`+assetName+`", "browser_download_url": "{server}/download/asset"}]}]`,
+		buf.Bytes())
+	path := setupInstalled(t, &registry.Executable{Source: "https://github.com/acme/tool", RepoID: 7, Version: "v1.0.0"})
+
+	if err := Run(Options{Name: "tool", Yes: true}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read updated executable: %v", err)
+	}
+	if string(data) != "#!/bin/sh\necho new\n" {
+		t.Errorf("updated executable = %q, want the zip's tool", data)
+	}
+	if exec := loadEntry(t); exec.Version != "v2.0.0" || exec.AssetName != assetName {
+		t.Errorf("registry records %s from %s, want v2.0.0 from %s", exec.Version, exec.AssetName, assetName)
+	}
+}
+
+func TestUpdateRecordsRepositoryWhenCurrent(t *testing.T) {
+	tests := []struct {
+		name       string
+		repository string
+		recordedID int64
+		opts       Options
+		wantSource string
+	}{
+		{
+			name:       "moved",
+			repository: `{"id": 7, "full_name": "newowner/tool"}`,
+			recordedID: 7,
+			opts:       Options{Name: "tool", Yes: true, AllowMoved: true},
+			wantSource: "https://github.com/newowner/tool",
+		},
+		{
+			name:       "missing repository ID",
+			repository: `{"id": 7, "full_name": "acme/tool"}`,
+			opts:       Options{Name: "tool", Yes: true},
+			wantSource: "https://github.com/acme/tool",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeGitHub(t, tt.repository, `[{"tag_name": "v1.0.0"}]`, nil)
+			setupInstalled(t, &registry.Executable{Source: "https://github.com/acme/tool", RepoID: tt.recordedID, Version: "v1.0.0"})
+
+			if err := Run(tt.opts); err != nil {
+				t.Fatalf("Run returned error: %v", err)
+			}
+			exec := loadEntry(t)
+			if exec.Source != tt.wantSource || exec.RepoID != 7 {
+				t.Errorf("registry records %s (id %d), want %s (id 7)", exec.Source, exec.RepoID, tt.wantSource)
+			}
+
+			// Having been recorded, the move needs no confirmation again.
+			if err := Run(Options{Name: "tool", Yes: true}); err != nil {
+				t.Errorf("second Run returned error: %v", err)
+			}
+		})
+	}
+}
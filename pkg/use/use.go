@@ -0,0 +1,30 @@
+// Package use switches a managed executable to another version kept in the
+// store.
+package use
+
+import (
+	"github.com/sfkleach/execman/pkg/rollback"
+	"github.com/spf13/cobra"
+)
+
+// NewUseCommand creates the use command.
+func NewUseCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "use <executable> <version>",
+		Short: "Switch an executable to another installed version",
+		Long: `Switch an executable to another version kept by execman, as listed by 'execman versions'.
+For executables installed with the versioned layout this atomically repoints the symlink in
+the install directory; otherwise the stored copy is restored as with 'execman rollback'.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Naming the version is confirmation enough.
+			return rollback.Run(rollback.Options{
+				Name:    args[0],
+				Version: args[1],
+				Yes:     true,
+			})
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,97 @@
+package use
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/archive"
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/store"
+)
+
+func TestUseSwitchesVersionedLink(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	// v1.0.0 and v2.0.0 are both in the store, and the link points at v2.0.0.
+	entries := make(map[string]*registry.Executable)
+	src := filepath.Join(home, "src")
+	for _, version := range []string{"v1.0.0", "v2.0.0"} {
+		if err := os.WriteFile(src, []byte(version), 0755); err != nil {
+			t.Fatalf("failed to write %s: %v", version, err)
+		}
+		path, err := store.Save(cfg.StoreDir, "tool", version, src)
+		if err != nil {
+			t.Fatalf("failed to store %s: %v", version, err)
+		}
+		checksum, err := archive.CalculateChecksum(path)
+		if err != nil {
+			t.Fatalf("failed to checksum %s: %v", version, err)
+		}
+		entries[version] = &registry.Executable{
+			Source:   "https://github.com/acme/tool",
+			Version:  version,
+			Path:     path,
+			Checksum: checksum,
+			Layout:   registry.LayoutVersioned,
+		}
+	}
+	link := filepath.Join(home, "bin", "tool")
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		t.Fatalf("failed to create bin directory: %v", err)
+	}
+	if err := os.Symlink(entries["v2.0.0"].Path, link); err != nil {
+		t.Fatalf("failed to link executable: %v", err)
+	}
+	current := entries["v2.0.0"].Snapshot(link)
+	current.History = []*registry.Executable{entries["v1.0.0"]}
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Add("tool", current)
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to save registry: %v", err)
+	}
+
+	cmd := NewUseCommand()
+	cmd.SetArgs([]string{"tool", "v1.0.0"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("use returned error: %v", err)
+	}
+
+	if target, err := os.Readlink(link); err != nil || target != entries["v1.0.0"].Path {
+		t.Errorf("link points at %q (%v), want %s", target, err, entries["v1.0.0"].Path)
+	}
+	reg, err := registry.Load()
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+	exec, found := reg.Get("tool")
+	if !found {
+		t.Fatal("tool is not in the registry")
+	}
+	if exec.Version != "v1.0.0" || len(exec.History) != 1 || exec.History[0].Version != "v2.0.0" {
+		t.Errorf("registry records %s with history %v, want v1.0.0 with v2.0.0 to switch back to", exec.Version, exec.History)
+	}
+
+	// Switching to the current version changes nothing.
+	cmd = NewUseCommand()
+	cmd.SetArgs([]string{"tool", "v1.0.0"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("second use returned error: %v", err)
+	}
+
+	// A version that is not kept cannot be switched to.
+	cmd = NewUseCommand()
+	cmd.SetArgs([]string{"tool", "v0.9.0"})
+	cmd.SilenceErrors, cmd.SilenceUsage = true, true
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected an error for a version that is not kept")
+	}
+}
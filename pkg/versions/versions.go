@@ -0,0 +1,89 @@
+// Package versions lists the versions of a managed executable that execman
+// keeps on disk.
+package versions
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/store"
+	"github.com/spf13/cobra"
+)
+
+// NewVersionsCommand creates the versions command.
+func NewVersionsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "versions <executable>",
+		Short: "List the versions of an executable kept on disk",
+		Long: `List the current version of an executable followed by the previous versions kept
+for rollback, most recent first. The current version is marked with '*'.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Run(args[0])
+		},
+	}
+
+	return cmd
+}
+
+// Run executes the versions command.
+func Run(name string) error {
+	reg, err := registry.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load registry: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	exec, ok := reg.Get(name)
+	if !ok {
+		return fmt.Errorf("executable %q is not managed by execman", name)
+	}
+
+	printVersion("*", exec.Version, exec.InstalledAt, statusOf(exec.Path))
+	known := map[string]bool{exec.Version: true}
+	for _, old := range exec.History {
+		printVersion(" ", old.Version, old.InstalledAt, statusOf(old.Path))
+		known[old.Version] = true
+	}
+
+	// Anything else in the store was left behind, for example by a registry
+	// restored from backup, and cannot be switched to.
+	onDisk, err := store.Versions(cfg.StoreDir, name)
+	if err != nil {
+		return err
+	}
+	for _, version := range onDisk {
+		if !known[version] {
+			printVersion(" ", version, time.Time{}, "untracked")
+		}
+	}
+
+	return nil
+}
+
+// statusOf describes a version whose file is not where the registry says.
+func statusOf(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return "missing"
+	}
+	return ""
+}
+
+// printVersion prints one line of the versions listing.
+func printVersion(marker, version string, installedAt time.Time, status string) {
+	line := fmt.Sprintf("%s %-20s", marker, version)
+	if !installedAt.IsZero() {
+		line += "  " + installedAt.Format("2006-01-02")
+	}
+	if status != "" {
+		line += "  (" + status + ")"
+	}
+	fmt.Println(line)
+}
@@ -0,0 +1,65 @@
+package versions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sfkleach/execman/pkg/config"
+	"github.com/sfkleach/execman/pkg/registry"
+	"github.com/sfkleach/execman/pkg/store"
+)
+
+func TestRun(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	// v2.0.0 is current, v1.0.0's stored copy is missing, and v0.9.0 is in
+	// the store without being in the history.
+	path := filepath.Join(home, "bin", "tool")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create bin directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("v2.0.0"), 0755); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+	if _, err := store.Save(cfg.StoreDir, "tool", "v0.9.0", path); err != nil {
+		t.Fatalf("failed to store v0.9.0: %v", err)
+	}
+	if err := registry.Update(func(r *registry.Registry) error {
+		r.Add("tool", &registry.Executable{
+			Source:  "https://github.com/acme/tool",
+			Version: "v2.0.0",
+			Path:    path,
+			History: []*registry.Executable{{Version: "v1.0.0", Path: store.Path(cfg.StoreDir, "tool", "v1.0.0")}},
+		})
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to save registry: %v", err)
+	}
+
+	if err := Run("tool"); err != nil {
+		t.Errorf("Run returned error: %v", err)
+	}
+	if err := Run("other"); err == nil {
+		t.Error("expected an error for an executable that is not managed")
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool")
+	if got := statusOf(path); got != "missing" {
+		t.Errorf("statusOf(missing file) = %q, want missing", got)
+	}
+	if err := os.WriteFile(path, []byte("tool"), 0755); err != nil {
+		t.Fatalf("failed to write executable: %v", err)
+	}
+	if got := statusOf(path); got != "" {
+		t.Errorf("statusOf(existing file) = %q, want none", got)
+	}
+}